@@ -0,0 +1,39 @@
+package muxprom
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type AccessLogEntry struct {
+	Time       time.Time
+	Route      string
+	Method     string
+	Path       string
+	RequestID  string
+	Status     int
+	Bytes      int
+	Duration   time.Duration
+	RemoteAddr string
+	ClientIP   string
+	UserAgent  string
+}
+
+func SlogAccessLogger(l *slog.Logger) func(AccessLogEntry) {
+	return func(e AccessLogEntry) {
+		l.LogAttrs(context.Background(), slog.LevelInfo, "http request",
+			slog.Time("time", e.Time),
+			slog.String("route", e.Route),
+			slog.String("method", e.Method),
+			slog.String("path", e.Path),
+			slog.String("request_id", e.RequestID),
+			slog.Int("status", e.Status),
+			slog.Int("bytes", e.Bytes),
+			slog.Duration("duration", e.Duration),
+			slog.String("remote_addr", e.RemoteAddr),
+			slog.String("client_ip", e.ClientIP),
+			slog.String("user_agent", e.UserAgent),
+		)
+	}
+}
@@ -0,0 +1,94 @@
+package muxprom
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func noRelease() {}
+
+// policyRoute returns the route that rate limits, shedding, concurrency
+// limits, caching, body limits and CORS are configured for: the gorilla/mux
+// route name, the path template of an unnamed route or the ServeMux pattern,
+// otherwise the label of the RouteLabeler given to New. Unlike the route
+// label it doesn't change with Reconfigure or the label normalization options.
+func (prom *MuxProm) policyRoute(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if name := route.GetName(); name != "" {
+			return name
+		}
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	} else if prom.ServeMux != nil {
+		if _, pattern := prom.ServeMux.Handler(r); pattern != "" {
+			return pattern
+		}
+		return unknownRoute
+	}
+	return prom.policyLabeler(r)
+}
+
+// serveAdmitted runs next behind the admission policies, the response cache
+// and compression of route. It is used whether or not the request is
+// instrumented, so disabling or closing muxprom never lifts a policy.
+func (prom *MuxProm) serveAdmitted(w http.ResponseWriter, r *http.Request, route string, next http.Handler) {
+	release, ok := prom.admit(w, r, route)
+	if !ok {
+		return
+	}
+	defer release()
+	if prom.cache != nil {
+		next = prom.cache.handler(route, next)
+	}
+	if prom.compression != nil {
+		next = prom.compression.handler(route, next)
+	}
+	next.ServeHTTP(w, r)
+}
+
+// admit decides whether the request reaches the handler. When it does, the
+// returned release func must be called once the handler is done.
+func (prom *MuxProm) admit(w http.ResponseWriter, r *http.Request, route string) (func(), bool) {
+	release := noRelease
+	if prom.cors != nil && !prom.cors.handle(w, r, route) {
+		return noRelease, false
+	}
+	if prom.bodyRejected != nil && !prom.limitBody(w, r, route) {
+		return noRelease, false
+	}
+	if prom.shedder != nil {
+		var ok bool
+		if release, ok = prom.shedder.acquire(route); !ok {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return noRelease, false
+		}
+	}
+	if prom.rateLimiters != nil {
+		if ok, wait := prom.rateLimiters.allow(route, prom.Clock.Now()); !ok {
+			release()
+			if wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			}
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return noRelease, false
+		}
+	}
+	if prom.concurrency != nil {
+		slot, ok := prom.concurrency.acquire(r.Context(), route)
+		if !ok {
+			release()
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return noRelease, false
+		}
+		shed := release
+		release = func() {
+			slot()
+			shed()
+		}
+	}
+	return release, true
+}
@@ -0,0 +1,196 @@
+package muxprom
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newPolicyRouter(t *testing.T, options ...func(*MuxProm)) (*mux.Router, *MuxProm) {
+	t.Helper()
+	router := mux.NewRouter()
+	router.Name("Users").Methods("GET", "POST", "OPTIONS").Path("/users/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var mbe *http.MaxBytesError
+		if _, err := io.ReadAll(r.Body); errors.As(err, &mbe) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write([]byte(strings.Repeat("user ", 100)))
+	})
+	prom, err := New(append([]func(*MuxProm){Router(router), Registry(prometheus.NewRegistry())}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prom.Instrument(); err != nil {
+		t.Fatal(err)
+	}
+	return router, prom
+}
+
+func TestAdmissionPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*MuxProm)
+		prepare func(*MuxProm)
+		request func() *http.Request
+		want    []int
+		header  string
+	}{
+		{
+			name:    "rate limit",
+			options: []func(*MuxProm){RateLimit("Users", 0, 1)},
+			want:    []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:    "rate limit while disabled",
+			options: []func(*MuxProm){RateLimit("Users", 0, 1)},
+			prepare: func(prom *MuxProm) { prom.Disable() },
+			want:    []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:    "rate limit after close",
+			options: []func(*MuxProm){RateLimit("Users", 0, 1)},
+			prepare: func(prom *MuxProm) { prom.Close() },
+			want:    []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:    "rate limit for skipped method",
+			options: []func(*MuxProm){RateLimit("Users", 0, 1), SkipMethods("GET")},
+			want:    []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:    "rate limit keyed on route name",
+			options: []func(*MuxProm){RateLimit("Users", 0, 1), LowercaseRouteLabels(true), StripTrailingSlash(true), AggregateMethods("GET")},
+			want:    []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:    "rate limit on other route",
+			options: []func(*MuxProm){RateLimit("users", 0, 1)},
+			want:    []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:    "route shedding",
+			options: []func(*MuxProm){ShedRouteInflight("Users", 0)},
+			want:    []int{http.StatusServiceUnavailable},
+		},
+		{
+			name:    "body limit",
+			options: []func(*MuxProm){MaxBodySize("Users", 4)},
+			request: func() *http.Request { return httptest.NewRequest("POST", "/users/", strings.NewReader("too long")) },
+			want:    []int{http.StatusRequestEntityTooLarge},
+		},
+		{
+			name:    "body limit without content length",
+			options: []func(*MuxProm){MaxBodySize("Users", 4), SkipMethods("POST")},
+			request: func() *http.Request {
+				r := httptest.NewRequest("POST", "/users/", strings.NewReader("too long"))
+				r.ContentLength = -1
+				return r
+			},
+			want: []int{http.StatusRequestEntityTooLarge},
+		},
+		{
+			name: "cors preflight for skipped method",
+			options: []func(*MuxProm){
+				CORS(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}),
+				SkipMethods("OPTIONS"),
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest("OPTIONS", "/users/", nil)
+				r.Header.Set("Origin", "https://app.example.com")
+				r.Header.Set("Access-Control-Request-Method", "POST")
+				return r
+			},
+			want:   []int{http.StatusNoContent},
+			header: "Access-Control-Allow-Origin",
+		},
+		{
+			name:    "cors rejected preflight",
+			options: []func(*MuxProm){CORS(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})},
+			request: func() *http.Request {
+				r := httptest.NewRequest("OPTIONS", "/users/", nil)
+				r.Header.Set("Origin", "https://evil.example.com")
+				r.Header.Set("Access-Control-Request-Method", "POST")
+				return r
+			},
+			want: []int{http.StatusForbidden},
+		},
+		{
+			name:    "compression while disabled",
+			options: []func(*MuxProm){Compression(true)},
+			prepare: func(prom *MuxProm) { prom.Disable() },
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/users/", nil)
+				r.Header.Set("Accept-Encoding", "gzip")
+				return r
+			},
+			want:   []int{http.StatusOK},
+			header: "Content-Encoding",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, prom := newPolicyRouter(t, append(tt.options, WithClock(fixedClock{}))...)
+			if tt.prepare != nil {
+				tt.prepare(prom)
+			}
+			for i, want := range tt.want {
+				r := httptest.NewRequest("GET", "/users/", nil)
+				if tt.request != nil {
+					r = tt.request()
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, r)
+				if rec.Code != want {
+					t.Fatalf("request %d: status %d, want %d", i, rec.Code, want)
+				}
+				if tt.header != "" && rec.Header().Get(tt.header) == "" {
+					t.Fatalf("request %d: %s not set", i, tt.header)
+				}
+			}
+		})
+	}
+}
+
+// metricValue sums the values, or the sample counts of histograms and
+// summaries, of metric name whose labels include the given pairs.
+func metricValue(t *testing.T, prom *MuxProm, name string, labels ...string) float64 {
+	t.Helper()
+	mfs, err := prom.gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v float64
+	for _, mf := range mfs {
+		if mf.GetName() != prom.Namespace+"_"+name {
+			continue
+		}
+	metrics:
+		for _, m := range mf.Metric {
+			for i := 0; i+1 < len(labels); i += 2 {
+				found := false
+				for _, l := range m.Label {
+					found = found || l.GetName() == labels[i] && l.GetValue() == labels[i+1]
+				}
+				if !found {
+					continue metrics
+				}
+			}
+			v += m.GetCounter().GetValue() + m.GetGauge().GetValue()
+			v += float64(m.GetHistogram().GetSampleCount() + m.GetSummary().GetSampleCount())
+		}
+	}
+	return v
+}
+
+type fixedClock struct{}
+
+func (fixedClock) Now() time.Time                  { return time.Unix(1700000000, 0) }
+func (fixedClock) Since(t time.Time) time.Duration { return time.Unix(1700000000, 0).Sub(t) }
@@ -0,0 +1,160 @@
+package muxprom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Observations travel as one datagram each: kind, route, method, status and
+// value separated by tabs.
+const (
+	aggregateIncInflight = "i"
+	aggregateDecInflight = "d"
+	aggregateDuration    = "t"
+	aggregateSize        = "s"
+)
+
+var aggregateFieldReplacer = strings.NewReplacer("\t", "_", "\n", "_")
+
+type WorkerRecorder struct {
+	conn net.Conn
+}
+
+func NewWorkerRecorder(socketPath string) (*WorkerRecorder, error) {
+	conn, err := net.Dial("unixgram", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkerRecorder{conn: conn}, nil
+}
+
+func (w *WorkerRecorder) Close() error {
+	return w.conn.Close()
+}
+
+func (w *WorkerRecorder) IncInflight(route string, method string) {
+	w.send(aggregateIncInflight, route, method, 0, "")
+}
+
+func (w *WorkerRecorder) DecInflight(route string, method string) {
+	w.send(aggregateDecInflight, route, method, 0, "")
+}
+
+func (w *WorkerRecorder) ObserveDuration(ctx context.Context, route string, method string, status int, d time.Duration) {
+	w.send(aggregateDuration, route, method, status, strconv.FormatInt(int64(d), 10))
+}
+
+func (w *WorkerRecorder) ObserveSize(ctx context.Context, route string, method string, status int, bytes int) {
+	w.send(aggregateSize, route, method, status, strconv.Itoa(bytes))
+}
+
+func (w *WorkerRecorder) send(kind string, route string, method string, status int, value string) {
+	msg := strings.Join([]string{
+		kind,
+		aggregateFieldReplacer.Replace(route),
+		aggregateFieldReplacer.Replace(method),
+		strconv.Itoa(status),
+		value,
+	}, "\t")
+	// Errors are ignored: a missing aggregator must not fail requests.
+	w.conn.Write([]byte(msg))
+}
+
+type Aggregator struct {
+	prom *MuxProm
+	conn *net.UnixConn
+	path string
+	done chan struct{}
+}
+
+func NewAggregator(socketPath string, options ...func(*MuxProm)) (*Aggregator, error) {
+	prom, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		prom.Close()
+		return nil, fmt.Errorf("muxprom: removing stale socket failed: %w", err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		prom.Close()
+		return nil, err
+	}
+	a := &Aggregator{prom: prom, conn: conn, path: socketPath, done: make(chan struct{})}
+	go a.serve()
+	return a, nil
+}
+
+func (a *Aggregator) Handler() http.Handler {
+	return a.prom.Handler()
+}
+
+func (a *Aggregator) Close() error {
+	err := a.conn.Close()
+	<-a.done
+	os.Remove(a.path)
+	if cerr := a.prom.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (a *Aggregator) serve() {
+	defer close(a.done)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := a.conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			a.prom.Logger.Warn("muxprom: reading from worker socket failed", "error", err)
+			continue
+		}
+		if err := a.record(string(buf[:n])); err != nil {
+			a.prom.Logger.Debug("muxprom: dropping malformed worker observation", "error", err)
+		}
+	}
+}
+
+func (a *Aggregator) record(msg string) error {
+	fields := strings.Split(msg, "\t")
+	if len(fields) != 5 {
+		return fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+	kind, route, method := fields[0], fields[1], fields[2]
+	status, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return fmt.Errorf("invalid status %q", fields[3])
+	}
+	r := a.prom.recorder
+	switch kind {
+	case aggregateIncInflight:
+		r.IncInflight(route, method)
+	case aggregateDecInflight:
+		r.DecInflight(route, method)
+	case aggregateDuration:
+		ns, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", fields[4])
+		}
+		r.ObserveDuration(context.Background(), route, method, status, time.Duration(ns))
+	case aggregateSize:
+		bytes, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return fmt.Errorf("invalid size %q", fields[4])
+		}
+		r.ObserveSize(context.Background(), route, method, status, bytes)
+	default:
+		return fmt.Errorf("unknown kind %q", kind)
+	}
+	return nil
+}
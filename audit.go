@@ -0,0 +1,39 @@
+package muxprom
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type auditKey struct{}
+
+func newUnaccountedCounter(prom *MuxProm) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prom.Namespace,
+			Name:      "http_requests_unaccounted_total",
+			Help:      "HTTP requests seen by the audit handler that bypassed the instrumentation middleware",
+		},
+		[]string{"method"},
+	)
+}
+
+func (prom *MuxProm) Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var seen int32
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auditKey{}, &seen)))
+		if atomic.LoadInt32(&seen) == 0 && prom.Enabled() && !prom.isOwnRoute(r) {
+			prom.unaccounted.WithLabelValues(r.Method).Inc()
+			prom.Logger.Debug("muxprom: uninstrumented request", "method", r.Method, "path", r.URL.Path)
+		}
+	})
+}
+
+func markAudited(r *http.Request) {
+	if seen, ok := r.Context().Value(auditKey{}).(*int32); ok {
+		atomic.StoreInt32(seen, 1)
+	}
+}
@@ -0,0 +1,55 @@
+package muxprom
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newBodyRejected(prom *MuxProm) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prom.Namespace,
+			Name:      "http_request_body_rejected_total",
+			Help:      "HTTP requests whose body exceeded the route's size limit",
+		},
+		[]string{"route"},
+	)
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	exceeded func()
+	once     sync.Once
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		b.once.Do(b.exceeded)
+	}
+	return n, err
+}
+
+func (prom *MuxProm) limitBody(w http.ResponseWriter, r *http.Request, route string) bool {
+	limit, ok := prom.MaxBodySizes[route]
+	if !ok {
+		return true
+	}
+	if r.ContentLength > limit {
+		prom.bodyRejected.WithLabelValues(route).Inc()
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return false
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &limitedBody{
+			ReadCloser: http.MaxBytesReader(w, r.Body, limit),
+			exceeded:   func() { prom.bodyRejected.WithLabelValues(route).Inc() },
+		}
+	}
+	return true
+}
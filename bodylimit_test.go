@@ -0,0 +1,38 @@
+package muxprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		want          int
+		rejected      float64
+	}{
+		{name: "within limit", body: "tiny", contentLength: 4, want: http.StatusOK},
+		{name: "content length over limit", body: "too long", contentLength: 8, want: http.StatusRequestEntityTooLarge, rejected: 1},
+		{name: "chunked within limit", body: "tiny", contentLength: -1, want: http.StatusOK},
+		{name: "chunked over limit", body: "too long", contentLength: -1, want: http.StatusRequestEntityTooLarge, rejected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, prom := newPolicyRouter(t, MaxBodySize("Users", 4))
+			r := httptest.NewRequest("POST", "/users/", strings.NewReader(tt.body))
+			r.ContentLength = tt.contentLength
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+			if got := metricValue(t, prom, "http_request_body_rejected_total", "route", "Users"); got != tt.rejected {
+				t.Errorf("rejected %v, want %v", got, tt.rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package muxprom
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+var errBreakerServerError = errors.New("muxprom: handler responded with a server error")
+
+type CircuitBreaker interface {
+	Execute(req func() (interface{}, error)) (interface{}, error)
+}
+
+type breakerMetrics struct {
+	state *prometheus.GaugeVec
+	trips *prometheus.CounterVec
+}
+
+func newBreakerMetrics(prom *MuxProm) *breakerMetrics {
+	return &breakerMetrics{
+		state: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prom.Namespace,
+				Name:      "circuit_breaker_state",
+				Help:      "Current circuit breaker state by route (1 for the active state)",
+			},
+			[]string{"route", "state"},
+		),
+		trips: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "circuit_breaker_trips_total",
+				Help:      "Number of times a circuit breaker opened by route",
+			},
+			[]string{"route"},
+		),
+	}
+}
+
+func (prom *MuxProm) BreakerStateChanged(route string, from string, to string) {
+	if from != "" {
+		prom.breakers.state.WithLabelValues(route, from).Set(0)
+	}
+	prom.breakers.state.WithLabelValues(route, to).Set(1)
+	if to == BreakerOpen {
+		prom.breakers.trips.WithLabelValues(route).Inc()
+	}
+}
+
+func (prom *MuxProm) WrapBreaker(route string, b CircuitBreaker, next http.Handler) http.Handler {
+	for _, s := range []string{BreakerClosed, BreakerHalfOpen, BreakerOpen} {
+		prom.breakers.state.WithLabelValues(route, s)
+	}
+	prom.breakers.state.WithLabelValues(route, BreakerClosed).Set(1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ran := false
+		_, err := b.Execute(func() (interface{}, error) {
+			ran = true
+			sw := &statusWriter{ResponseWriter: w, clock: prom.Clock}
+			next.ServeHTTP(sw, r)
+			if sw.status >= 500 {
+				return nil, errBreakerServerError
+			}
+			return nil, nil
+		})
+		if err != nil && !ran {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package muxprom
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// countingBreaker opens after failures consecutive errors.
+type countingBreaker struct {
+	prom     *MuxProm
+	failures int
+	errors   int
+}
+
+func (b *countingBreaker) Execute(req func() (interface{}, error)) (interface{}, error) {
+	if b.errors >= b.failures {
+		return nil, errors.New("open")
+	}
+	v, err := req()
+	if err != nil {
+		if b.errors++; b.errors == b.failures {
+			b.prom.BreakerStateChanged("Users", BreakerClosed, BreakerOpen)
+		}
+	}
+	return v, err
+}
+
+func TestWrapBreaker(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   []int
+		open   float64
+		trips  float64
+	}{
+		{name: "healthy", status: http.StatusOK, want: []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+		{name: "client errors", status: http.StatusNotFound, want: []int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}},
+		{name: "server errors", status: http.StatusBadGateway, want: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusServiceUnavailable}, open: 1, trips: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, prom := newPolicyRouter(t)
+			b := &countingBreaker{prom: prom, failures: 2}
+			h := prom.WrapBreaker("Users", b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			for i, want := range tt.want {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest("GET", "/users/", nil))
+				if rec.Code != want {
+					t.Fatalf("request %d: status %d, want %d", i, rec.Code, want)
+				}
+			}
+			if got := metricValue(t, prom, "circuit_breaker_state", "route", "Users", "state", BreakerOpen); got != tt.open {
+				t.Errorf("open state %v, want %v", got, tt.open)
+			}
+			if got := metricValue(t, prom, "circuit_breaker_state", "route", "Users", "state", BreakerClosed); got != 1-tt.open {
+				t.Errorf("closed state %v, want %v", got, 1-tt.open)
+			}
+			if got := metricValue(t, prom, "circuit_breaker_trips_total", "route", "Users"); got != tt.trips {
+				t.Errorf("trips %v, want %v", got, tt.trips)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package muxprom
+
+import (
+	"math"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+func LatencyBucketsExponential(min, max time.Duration, count int) []float64 {
+	if min <= 0 || max <= min || count < 2 {
+		panic("muxprom: LatencyBucketsExponential needs 0 < min < max and count >= 2")
+	}
+	factor := math.Pow(max.Seconds()/min.Seconds(), 1/float64(count-1))
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = min.Seconds() * math.Pow(factor, float64(i))
+	}
+	// Avoid float drift on the upper bound.
+	buckets[count-1] = max.Seconds()
+	return buckets
+}
+
+func LatencyBucketsLinear(start, width time.Duration, count int) []float64 {
+	if start < 0 || width <= 0 || count < 1 {
+		panic("muxprom: LatencyBucketsLinear needs start >= 0, width > 0 and count >= 1")
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = (start + time.Duration(i)*width).Seconds()
+	}
+	return buckets
+}
+
+func SizeBucketsPowersOfTwo(maxMB int) []float64 {
+	if maxMB < 1 {
+		panic("muxprom: SizeBucketsPowersOfTwo needs maxMB >= 1")
+	}
+	buckets := []float64{0}
+	for size := float64(bytefmt.KILOBYTE); size < float64(maxMB)*bytefmt.MEGABYTE; size *= 2 {
+		buckets = append(buckets, size)
+	}
+	return append(buckets, float64(maxMB)*bytefmt.MEGABYTE)
+}
@@ -0,0 +1,273 @@
+package muxprom
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var defaultCacheMaxEntries = 1000
+var maxCachedBodySize = 1 << 20
+
+type cacheEntry struct {
+	key     string
+	base    string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+type responseCache struct {
+	prom *MuxProm
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// vary holds the Vary header names of the last cached response per
+	// route and request URI, so lookups can build the full key.
+	vary map[string][]string
+
+	hits      *prometheus.CounterVec
+	misses    *prometheus.CounterVec
+	saved     *prometheus.CounterVec
+	evictions prometheus.Counter
+}
+
+func newResponseCache(prom *MuxProm) *responseCache {
+	return &responseCache{
+		prom:    prom,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		vary:    make(map[string][]string),
+		hits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "response_cache_hits_total",
+				Help:      "Requests served from the response cache by route",
+			},
+			[]string{"route"},
+		),
+		misses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "response_cache_misses_total",
+				Help:      "Cacheable requests not found in the response cache by route",
+			},
+			[]string{"route"},
+		),
+		saved: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "response_cache_saved_bytes_total",
+				Help:      "Response body bytes served from the response cache by route",
+			},
+			[]string{"route"},
+		),
+		evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: prom.Namespace,
+			Name:      "response_cache_evictions_total",
+			Help:      "Entries evicted from the full response cache",
+		}),
+	}
+}
+
+func (c *responseCache) get(base string, r *http.Request, now time.Time) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[varyKey(base, c.vary[base], r)]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*cacheEntry)
+	if now.After(entry.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return entry
+}
+
+func (c *responseCache) put(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vary[entry.base] = varyNames(entry.header)
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.prom.CacheMaxEntries {
+		c.remove(c.lru.Back())
+		c.evictions.Inc()
+	}
+}
+
+// remove drops an entry. Other variants of the same URI are then looked up
+// without their Vary names, which only costs a miss.
+func (c *responseCache) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, entry.key)
+	delete(c.vary, entry.base)
+}
+
+func (c *responseCache) handler(route string, next http.Handler) http.Handler {
+	ttl, ok := c.prom.CacheRoutes[route]
+	if !ok {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		base := route + " " + r.URL.RequestURI()
+		if entry := c.get(base, r, c.prom.Clock.Now()); entry != nil {
+			c.hits.WithLabelValues(route).Inc()
+			c.saved.WithLabelValues(route).Add(float64(len(entry.body)))
+			// Headers set before the handler, e.g. CORS, belong to this
+			// request and are kept.
+			for k, v := range entry.header {
+				w.Header()[k] = append(w.Header()[k], v...)
+			}
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+		c.misses.WithLabelValues(route).Inc()
+		cw := &cachingWriter{ResponseWriter: w, before: w.Header().Clone()}
+		next.ServeHTTP(cw, r)
+		if cw.status != http.StatusOK || cw.tooLarge || !cacheable(cw.header) {
+			return
+		}
+		c.put(&cacheEntry{
+			key:     varyKey(base, varyNames(cw.header), r),
+			base:    base,
+			status:  cw.status,
+			header:  handlerHeader(cw.before, cw.header),
+			body:    cw.body.Bytes(),
+			expires: c.prom.Clock.Now().Add(ttl),
+		})
+	})
+}
+
+// cacheable reports whether a response may be replayed to other clients.
+func cacheable(h http.Header) bool {
+	if len(h.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, v := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name := strings.ToLower(strings.TrimSpace(directive))
+			if i := strings.IndexByte(name, '='); i >= 0 {
+				name = name[:i]
+			}
+			if name == "private" || name == "no-store" {
+				return false
+			}
+		}
+	}
+	for _, name := range varyNames(h) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+func varyKey(base string, names []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range names {
+		b.WriteString("\x00")
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// handlerHeader returns the header values added by the handler, leaving out
+// those set for the current request before it ran.
+func handlerHeader(before, after http.Header) http.Header {
+	h := make(http.Header, len(after))
+	for k, v := range after {
+		prev := before[k]
+		if len(prev) <= len(v) && equalStrings(prev, v[:len(prev)]) {
+			v = v[len(prev):]
+		}
+		if len(v) > 0 {
+			h[k] = append([]string(nil), v...)
+		}
+	}
+	return h
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type cachingWriter struct {
+	http.ResponseWriter
+	before   http.Header
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	tooLarge bool
+}
+
+func (w *cachingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cachingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.tooLarge {
+		if w.body.Len()+len(b) > maxCachedBodySize {
+			w.tooLarge = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cachingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,103 @@
+package muxprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestResponseCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{name: "public", want: 1},
+		{name: "cookie", header: http.Header{"Set-Cookie": {"session=1"}}, want: 2},
+		{name: "private", header: http.Header{"Cache-Control": {"private, max-age=60"}}, want: 2},
+		{name: "vary any", header: http.Header{"Vary": {"*"}}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			calls := 0
+			router.Name("cached").Path("/cached").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+				w.Write([]byte("body"))
+			})
+			prom, err := New(Router(router), Registry(prometheus.NewRegistry()), CacheRoute("cached", time.Minute), LowercaseRouteLabels(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := prom.Instrument(); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest("GET", "/cached", nil))
+				if rec.Code != http.StatusOK || rec.Body.String() != "body" {
+					t.Fatalf("request %d: %d %q", i, rec.Code, rec.Body.String())
+				}
+			}
+			if calls != tt.want {
+				t.Errorf("handler called %d times, want %d", calls, tt.want)
+			}
+			hits := float64(2 - tt.want)
+			if got := metricValue(t, prom, "response_cache_hits_total", "route", "cached"); got != hits {
+				t.Errorf("hits %v, want %v", got, hits)
+			}
+			if got := metricValue(t, prom, "response_cache_misses_total", "route", "cached"); got != float64(tt.want) {
+				t.Errorf("misses %v, want %v", got, tt.want)
+			}
+			if got := metricValue(t, prom, "response_cache_saved_bytes_total", "route", "cached"); got != hits*4 {
+				t.Errorf("saved bytes %v, want %v", got, hits*4)
+			}
+		})
+	}
+}
+
+func TestResponseCacheExpiryAndEviction(t *testing.T) {
+	router := mux.NewRouter()
+	calls := 0
+	router.Name("cached").Path("/cached/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("body"))
+	})
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	prom, err := New(Router(router), Registry(prometheus.NewRegistry()), CacheRoute("cached", time.Minute), CacheMaxEntries(1), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prom.Instrument(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		advance time.Duration
+		calls   int
+	}{
+		{path: "/cached/1", calls: 1},
+		{path: "/cached/1", calls: 1},
+		{path: "/cached/1", advance: 2 * time.Minute, calls: 2},
+		{path: "/cached/2", calls: 3},
+		{path: "/cached/1", calls: 4},
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+		if calls != tt.calls {
+			t.Fatalf("request %d: handler called %d times, want %d", i, calls, tt.calls)
+		}
+	}
+	if got := metricValue(t, prom, "response_cache_evictions_total"); got != 2 {
+		t.Errorf("evictions %v, want 2", got)
+	}
+}
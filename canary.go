@@ -0,0 +1,74 @@
+package muxprom
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const canaryLabel = "canary"
+
+type CanaryQueryConfig struct {
+	Namespace string
+	Window    string
+	Quantile  float64
+}
+
+type CanaryQueries struct {
+	TrafficShare   string
+	ErrorRatioDiff string
+	LatencyRatio   string
+}
+
+func Canary(canary bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Canary = canary
+	}
+}
+
+func (prom *MuxProm) constLabels() prometheus.Labels {
+	if !prom.Canary {
+		return prom.ConstLabels
+	}
+	labels := prometheus.Labels{canaryLabel: "true"}
+	for name, value := range prom.ConstLabels {
+		labels[name] = value
+	}
+	return labels
+}
+
+func (prom *MuxProm) CanaryQueries(window string) CanaryQueries {
+	return GenerateCanaryQueries(CanaryQueryConfig{Namespace: prom.Namespace, Window: window})
+}
+
+func GenerateCanaryQueries(cfg CanaryQueryConfig) CanaryQueries {
+	if cfg.Namespace == "" {
+		cfg.Namespace = defaultNamespace
+	}
+	if cfg.Window == "" {
+		cfg.Window = "5m"
+	}
+	if cfg.Quantile == 0 {
+		cfg.Quantile = 0.99
+	}
+	duration := cfg.Namespace + "_http_request_duration_seconds"
+	canary := canaryLabel + `="true"`
+	baseline := canaryLabel + `!="true"`
+
+	rate := func(sel string) string {
+		return fmt.Sprintf(`sum by (route) (rate(%s_count{%s}[%s]))`, duration, sel, cfg.Window)
+	}
+	errorRatio := func(sel string) string {
+		return fmt.Sprintf(`(%s / %s)`, rate(sel+`,http_status=~"5.."`), rate(sel))
+	}
+	quantile := func(sel string) string {
+		return fmt.Sprintf(`histogram_quantile(%s, sum by (route, le) (rate(%s_bucket{%s}[%s])))`,
+			formatFloat(cfg.Quantile), duration, sel, cfg.Window)
+	}
+
+	return CanaryQueries{
+		TrafficShare:   fmt.Sprintf(`%s / sum by (route) (rate(%s_count[%s]))`, rate(canary), duration, cfg.Window),
+		ErrorRatioDiff: errorRatio(canary) + " - " + errorRatio(baseline),
+		LatencyRatio:   quantile(canary) + " / " + quantile(baseline),
+	}
+}
@@ -0,0 +1,86 @@
+package muxprom
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+var defaultRouteCardinalityThreshold = 100
+
+// routeOverflowBits is the size of the bitmap counting route labels beyond
+// the tracked set.
+const routeOverflowBits = 1 << 16
+
+type routeCardinality struct {
+	mu     sync.RWMutex
+	seen   map[string]struct{}
+	warned bool
+	// overflow approximates the number of distinct labels that didn't fit
+	// into seen by linear counting, so a label explosion takes fixed memory.
+	overflow    []uint64
+	overflowSet int
+	seed        maphash.Seed
+}
+
+func (c *routeCardinality) count() int {
+	n := len(c.seen)
+	if c.overflow == nil {
+		return n
+	}
+	set := c.overflowSet
+	if set == routeOverflowBits {
+		set--
+	}
+	m := float64(routeOverflowBits)
+	return n + int(math.Round(-m*math.Log((m-float64(set))/m)))
+}
+
+func (prom *MuxProm) recordRouteLabel(route string) {
+	c := &prom.cardinality
+	c.mu.RLock()
+	_, ok := c.seen[route]
+	c.mu.RUnlock()
+	if ok {
+		return
+	}
+
+	limit := prom.RouteCardinalityThreshold
+	if limit <= 0 {
+		limit = defaultRouteCardinalityThreshold
+	}
+	c.mu.Lock()
+	if _, ok := c.seen[route]; !ok && len(c.seen) < limit {
+		c.seen[route] = struct{}{}
+	} else if !ok {
+		if c.overflow == nil {
+			c.overflow = make([]uint64, routeOverflowBits/64)
+			c.seed = maphash.MakeSeed()
+		}
+		bit := maphash.String(c.seed, route) % routeOverflowBits
+		if mask := uint64(1) << (bit % 64); c.overflow[bit/64]&mask == 0 {
+			c.overflow[bit/64] |= mask
+			c.overflowSet++
+		}
+	}
+	n := c.count()
+	warn := !c.warned && prom.RouteCardinalityThreshold > 0 && n > prom.RouteCardinalityThreshold
+	if warn {
+		c.warned = true
+	}
+	c.mu.Unlock()
+
+	if warn {
+		prom.Logger.Warn("muxprom: route label cardinality exceeds threshold",
+			"cardinality", n,
+			"threshold", prom.RouteCardinalityThreshold,
+			"route", route,
+		)
+	}
+}
+
+func (prom *MuxProm) RouteCardinality() int {
+	prom.cardinality.mu.RLock()
+	defer prom.cardinality.mu.RUnlock()
+	return prom.cardinality.count()
+}
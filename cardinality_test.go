@@ -0,0 +1,105 @@
+package muxprom
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRouteCardinality(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		labels    int
+		repeat    int
+	}{
+		{name: "below threshold", threshold: 10, labels: 5, repeat: 3},
+		{name: "above threshold", threshold: 10, labels: 5000, repeat: 2},
+		{name: "warning disabled", threshold: 0, labels: 3000, repeat: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prom, err := New(Registry(prometheus.NewRegistry()), RouteCardinalityThreshold(tt.threshold))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for r := 0; r < tt.repeat; r++ {
+				for i := 0; i < tt.labels; i++ {
+					prom.recordRouteLabel(fmt.Sprintf("/items/%d", i))
+				}
+			}
+
+			limit := tt.threshold
+			if limit <= 0 {
+				limit = defaultRouteCardinalityThreshold
+			}
+			if n := len(prom.cardinality.seen); n > limit {
+				t.Errorf("tracking %d labels exactly, want at most %d", n, limit)
+			}
+			got := prom.RouteCardinality()
+			if tt.labels <= limit && got != tt.labels {
+				t.Errorf("cardinality %d, want %d", got, tt.labels)
+			}
+			if diff := float64(got-tt.labels) / float64(tt.labels); diff < -0.05 || diff > 0.05 {
+				t.Errorf("cardinality %d, want about %d", got, tt.labels)
+			}
+		})
+	}
+}
+
+type warnRecorder struct {
+	Logger
+	mu    sync.Mutex
+	warns []string
+}
+
+func (l *warnRecorder) Warn(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, fmt.Sprint(append([]any{msg}, args...)...))
+}
+
+func TestRouteCardinalityWarning(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		paths     int
+		warnings  int
+	}{
+		{name: "below threshold", threshold: 5, paths: 5},
+		{name: "above threshold", threshold: 5, paths: 50, warnings: 1},
+		{name: "warning disabled", threshold: 0, paths: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &warnRecorder{Logger: defaultLogger()}
+			prom, err := New(
+				Registry(prometheus.NewRegistry()),
+				RouteLabeler(func(r *http.Request) string { return r.URL.Path }),
+				RouteCardinalityThreshold(tt.threshold),
+				WithLogger(logger),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := prom.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			for i := 0; i < tt.paths; i++ {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest("GET", fmt.Sprintf("/items/%d", i), nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("request %d: status %d", i, rec.Code)
+				}
+			}
+			if got := prom.RouteCardinality(); got != tt.paths {
+				t.Errorf("cardinality %d, want %d", got, tt.paths)
+			}
+			if len(logger.warns) != tt.warnings {
+				t.Errorf("warnings %q, want %d", logger.warns, tt.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package muxprom
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type roundTripper struct {
+	next                 http.RoundTripper
+	clock                Clock
+	reqInFlight          *prometheus.GaugeVec
+	reqDurationHistogram *prometheus.HistogramVec
+	reqRespSizeHistogram *prometheus.HistogramVec
+	reqRespSizeLegacy    *prometheus.HistogramVec
+}
+
+func RoundTripper(next http.RoundTripper, options ...func(prom *MuxProm)) http.RoundTripper {
+	p := defaults()
+	for _, option := range options {
+		option(p)
+	}
+	rt, err := newRoundTripper(next, p)
+	if err != nil {
+		// Requests still go through; only the metrics are not exported.
+		p.Logger.Error("muxprom: outbound request metrics are not registered", "error", err)
+	}
+	return rt
+}
+
+func NewRoundTripper(next http.RoundTripper, options ...func(prom *MuxProm)) (http.RoundTripper, error) {
+	p := defaults()
+	for _, option := range options {
+		option(p)
+	}
+	rt, err := newRoundTripper(next, p)
+	if err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+func newRoundTripper(next http.RoundTripper, p *MuxProm) (*roundTripper, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	rt := &roundTripper{
+		next:  next,
+		clock: p.Clock,
+		reqInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: p.Namespace,
+				Name:      "http_client_requests_inflight",
+				Help:      "HTTP client requests in-flight",
+			},
+			[]string{"host", "method"},
+		),
+		reqDurationHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: p.Namespace,
+				Name:      "http_client_request_duration_seconds",
+				Help:      "HTTP client request duration seconds",
+				Buckets:   p.DurationBucket,
+			},
+			[]string{"host", "method", "http_status"},
+		),
+		reqRespSizeHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: p.Namespace,
+				Name:      sizeMetricName(p.SchemaVersion, "http_client_response_size"),
+				Help:      "HTTP client response size in bytes",
+				Buckets:   p.RespSizeBucket,
+			},
+			[]string{"host", "method", "http_status"},
+		),
+	}
+	if p.LegacyMetricNames && p.SchemaVersion >= SchemaV2 {
+		rt.reqRespSizeLegacy = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: p.Namespace,
+				Name:      "http_client_response_size",
+				Help:      "HTTP client response size in bytes (deprecated, use http_client_response_size_bytes)",
+				Buckets:   p.RespSizeBucket,
+			},
+			[]string{"host", "method", "http_status"},
+		)
+	}
+	if p.Registerer == nil {
+		return rt, nil
+	}
+	reg := p.wrapRegisterer(p.Registerer)
+	collectors := []prometheus.Collector{rt.reqInFlight, rt.reqDurationHistogram, rt.reqRespSizeHistogram}
+	if rt.reqRespSizeLegacy != nil {
+		collectors = append(collectors, rt.reqRespSizeLegacy)
+	}
+	var registered []prometheus.Collector
+	for i, c := range collectors {
+		existing, err := registerOrExisting(reg, c)
+		if err != nil {
+			// Don't leave the collectors registered so far behind: the
+			// RoundTripper never observes them, and they would block a retry.
+			for _, c := range registered {
+				reg.Unregister(c)
+			}
+			return rt, err
+		}
+		if existing == c {
+			registered = append(registered, c)
+		}
+		collectors[i] = existing
+	}
+	rt.reqInFlight = collectors[0].(*prometheus.GaugeVec)
+	rt.reqDurationHistogram = collectors[1].(*prometheus.HistogramVec)
+	rt.reqRespSizeHistogram = collectors[2].(*prometheus.HistogramVec)
+	if rt.reqRespSizeLegacy != nil {
+		rt.reqRespSizeLegacy = collectors[3].(*prometheus.HistogramVec)
+	}
+	return rt, nil
+}
+
+// registerOrExisting registers c, or returns the identical collector of
+// another RoundTripper that is already registered.
+func registerOrExisting(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok && reflect.TypeOf(are.ExistingCollector) == reflect.TypeOf(c) {
+		return are.ExistingCollector, nil
+	}
+	return nil, fmt.Errorf("muxprom: registering client collectors failed: %w", err)
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	rt.reqInFlight.WithLabelValues(host, req.Method).Inc()
+	defer rt.reqInFlight.WithLabelValues(host, req.Method).Dec()
+
+	start := rt.clock.Now()
+	resp, err := rt.next.RoundTrip(req)
+	duration := rt.clock.Since(start)
+	if err != nil {
+		rt.reqDurationHistogram.WithLabelValues(host, req.Method, "error").Observe(duration.Seconds())
+		return resp, err
+	}
+
+	status := strconv.Itoa(resp.StatusCode)
+	rt.reqDurationHistogram.WithLabelValues(host, req.Method, status).Observe(duration.Seconds())
+	var size prometheus.Observer = rt.reqRespSizeHistogram.WithLabelValues(host, req.Method, status)
+	if rt.reqRespSizeLegacy != nil {
+		current, legacy := size, rt.reqRespSizeLegacy.WithLabelValues(host, req.Method, status)
+		size = prometheus.ObserverFunc(func(v float64) {
+			current.Observe(v)
+			legacy.Observe(v)
+		})
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		size.Observe(0)
+	} else {
+		resp.Body = &countingBody{ReadCloser: resp.Body, observer: size}
+	}
+	return resp, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+	observer prometheus.Observer
+	length   int
+	once     sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.length += n
+	if err == io.EOF {
+		b.observe()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.observe()
+	return b.ReadCloser.Close()
+}
+
+func (b *countingBody) observe() {
+	b.once.Do(func() {
+		b.observer.Observe(float64(b.length))
+	})
+}
@@ -0,0 +1,47 @@
+package muxprom
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type errorRecorder struct {
+	Logger
+	args []any
+}
+
+func (l *errorRecorder) Error(msg string, args ...any) {
+	l.args = args
+}
+
+func TestRoundTripperRegistrationFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		conflict string
+	}{
+		{name: "duration", conflict: "http_client_request_duration_seconds"},
+		{name: "size", conflict: "http_client_response_size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Namespace: "muxprom", Name: tt.conflict, Help: "conflict"}))
+			logger := &errorRecorder{Logger: defaultLogger()}
+			RoundTripper(http.DefaultTransport, Registry(reg), WithLogger(logger))
+			if len(logger.args) < 2 || logger.args[0] != "error" {
+				t.Errorf("logged %v, want an error key", logger.args)
+			}
+
+			inflight := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: "muxprom",
+				Name:      "http_client_requests_inflight",
+				Help:      "HTTP client requests in-flight",
+			}, []string{"host", "method"})
+			if err := reg.Register(inflight); err != nil {
+				t.Errorf("in-flight gauge left registered: %v", err)
+			}
+		})
+	}
+}
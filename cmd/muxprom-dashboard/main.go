@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rusart/muxprom"
+)
+
+func main() {
+	title := flag.String("title", "", "dashboard title (default: namespace)")
+	namespace := flag.String("namespace", "muxprom", "Prometheus namespace used by muxprom")
+	schema := flag.Int("schema", muxprom.SchemaV1, "metric schema version used by muxprom")
+	routes := flag.String("routes", "", "comma separated route labels to add per-route panels for")
+	buckets := flag.String("buckets", "", "comma separated duration buckets in seconds configured in muxprom (default: muxprom's default buckets)")
+	output := flag.String("o", "", "output file (default: stdout)")
+	flag.Parse()
+
+	cfg := muxprom.DashboardConfig{
+		Title:         *title,
+		Namespace:     *namespace,
+		SchemaVersion: *schema,
+	}
+	for _, route := range strings.Split(*routes, ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			cfg.Routes = append(cfg.Routes, route)
+		}
+	}
+
+	for _, bucket := range strings.Split(*buckets, ",") {
+		if bucket = strings.TrimSpace(bucket); bucket == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(bucket, 64)
+		if err != nil {
+			log.Fatalf("invalid bucket %q: %v", bucket, err)
+		}
+		cfg.DurationBucket = append(cfg.DurationBucket, v)
+	}
+
+	b, err := muxprom.GenerateDashboard(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b = append(b, '\n')
+	if *output == "" {
+		_, err = os.Stdout.Write(b)
+	} else {
+		err = ioutil.WriteFile(*output, b, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,172 @@
+package muxprom
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type compressionMetrics struct {
+	uncompressed *prometheus.HistogramVec
+	ratio        *prometheus.SummaryVec
+}
+
+func newCompressionMetrics(prom *MuxProm) *compressionMetrics {
+	return &compressionMetrics{
+		uncompressed: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_response_uncompressed_size_bytes",
+				Help:      "HTTP response size in bytes before compression",
+				Buckets:   prom.RespSizeBucket,
+			},
+			[]string{"route"},
+		),
+		ratio: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace:  prom.Namespace,
+				Name:       "http_response_compression_ratio",
+				Help:       "Compressed to uncompressed HTTP response size ratio",
+				Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+			},
+			[]string{"route"},
+		),
+	}
+}
+
+func acceptedEncoding(r *http.Request) string {
+	var deflate bool
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(fields) > 1 && strings.ReplaceAll(strings.TrimSpace(fields[1]), " ", "") == "q=0" {
+			continue
+		}
+		switch name {
+		case "gzip":
+			return "gzip"
+		case "deflate":
+			deflate = true
+		}
+	}
+	if deflate {
+		return "deflate"
+	}
+	return ""
+}
+
+func (c *compressionMetrics) handler(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r)
+		if encoding == "" || r.Method == http.MethodHead || isUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		next.ServeHTTP(cw, r)
+		if cw.compressor == nil {
+			return
+		}
+		cw.compressor.Close()
+		c.uncompressed.WithLabelValues(route).Observe(float64(cw.uncompressed))
+		if cw.uncompressed > 0 {
+			c.ratio.WithLabelValues(route).Observe(float64(cw.wire.n) / float64(cw.uncompressed))
+		}
+	})
+}
+
+// isUpgrade reports whether the client asks to switch protocols, e.g. to a
+// websocket, whose connection must not be compressed.
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" {
+		return true
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += n
+	return n, err
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding     string
+	decided      bool
+	compressor   io.WriteCloser
+	wire         countingWriter
+	uncompressed int
+}
+
+func (w *compressWriter) decide(status int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+	h.Set("Content-Encoding", w.encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	w.wire.w = w.ResponseWriter
+	if w.encoding == "gzip" {
+		w.compressor = gzip.NewWriter(&w.wire)
+	} else {
+		w.compressor, _ = flate.NewWriter(&w.wire, flate.DefaultCompression)
+	}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	w.decide(status)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	w.uncompressed += len(b)
+	return w.compressor.Write(b)
+}
+
+func (w *compressWriter) Flush() {
+	if f, ok := w.compressor.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	writer, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("not supported by the underlying writer")
+	}
+	return writer.Hijack()
+}
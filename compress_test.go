@@ -0,0 +1,76 @@
+package muxprom
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		upgrade        bool
+		want           string
+	}{
+		{name: "gzip", acceptEncoding: "deflate, gzip", want: "gzip"},
+		{name: "deflate", acceptEncoding: "deflate", want: "deflate"},
+		{name: "gzip refused", acceptEncoding: "gzip;q=0, deflate", want: "deflate"},
+		{name: "identity", acceptEncoding: "br"},
+		{name: "head", method: "HEAD", acceptEncoding: "gzip"},
+		{name: "upgrade", acceptEncoding: "gzip", upgrade: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, prom := newPolicyRouter(t, Compression(true))
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			r := httptest.NewRequest(method, "/users/", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.upgrade {
+				r.Header.Set("Connection", "Upgrade")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, r)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding %q, want %q", got, tt.want)
+			}
+			var body io.Reader = rec.Body
+			switch tt.want {
+			case "gzip":
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			case "deflate":
+				body = flate.NewReader(rec.Body)
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method == "GET" && string(b) != strings.Repeat("user ", 100) {
+				t.Errorf("body %q", b)
+			}
+
+			compressed := 0.0
+			if tt.want != "" {
+				compressed = 1
+			}
+			if got := metricValue(t, prom, "http_response_uncompressed_size_bytes", "route", "Users"); got != compressed {
+				t.Errorf("uncompressed size observations %v, want %v", got, compressed)
+			}
+			if got := metricValue(t, prom, "http_response_compression_ratio", "route", "Users"); got != compressed {
+				t.Errorf("compression ratio observations %v, want %v", got, compressed)
+			}
+		})
+	}
+}
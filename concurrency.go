@@ -0,0 +1,111 @@
+package muxprom
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type RouteConcurrencyLimit struct {
+	Route string
+	Limit int
+	Queue int
+}
+
+type routeLimiter struct {
+	slots  chan struct{}
+	queue  int64
+	queued atomic.Int64
+}
+
+type concurrencyLimiters struct {
+	prom     *MuxProm
+	routes   map[string]*routeLimiter
+	depth    *prometheus.GaugeVec
+	wait     *prometheus.HistogramVec
+	rejected *prometheus.CounterVec
+}
+
+func validConcurrencyLimits(limits []RouteConcurrencyLimit) error {
+	for _, cl := range limits {
+		if cl.Limit <= 0 {
+			return fmt.Errorf("muxprom: concurrency limit %d of route %q is not positive", cl.Limit, cl.Route)
+		}
+		if cl.Queue < 0 {
+			return fmt.Errorf("muxprom: queue length %d of route %q is negative", cl.Queue, cl.Route)
+		}
+	}
+	return nil
+}
+
+func newConcurrencyLimiters(prom *MuxProm) *concurrencyLimiters {
+	c := &concurrencyLimiters{
+		prom:   prom,
+		routes: make(map[string]*routeLimiter),
+		depth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_queue_depth",
+				Help:      "HTTP requests waiting for a concurrency slot by route",
+			},
+			[]string{"route"},
+		),
+		wait: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_queue_wait_seconds",
+				Help:      "Time HTTP requests waited for a concurrency slot by route",
+				Buckets:   prom.DurationBucket,
+			},
+			[]string{"route"},
+		),
+		rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_queue_rejected_total",
+				Help:      "HTTP requests rejected because the concurrency queue was full or the request was cancelled while waiting",
+			},
+			[]string{"route"},
+		),
+	}
+	for _, cl := range prom.ConcurrencyLimits {
+		c.routes[cl.Route] = &routeLimiter{slots: make(chan struct{}, cl.Limit), queue: int64(cl.Queue)}
+		c.depth.WithLabelValues(cl.Route)
+	}
+	return c
+}
+
+func (c *concurrencyLimiters) acquire(ctx context.Context, route string) (func(), bool) {
+	l, ok := c.routes[route]
+	if !ok {
+		return noRelease, true
+	}
+	release := func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, true
+	default:
+	}
+
+	if l.queued.Add(1) > l.queue {
+		l.queued.Add(-1)
+		c.rejected.WithLabelValues(route).Inc()
+		return noRelease, false
+	}
+	depth := c.depth.WithLabelValues(route)
+	depth.Inc()
+	defer depth.Dec()
+	defer l.queued.Add(-1)
+
+	start := c.prom.Clock.Now()
+	select {
+	case l.slots <- struct{}{}:
+		c.wait.WithLabelValues(route).Observe(c.prom.Clock.Since(start).Seconds())
+		return release, true
+	case <-ctx.Done():
+		c.rejected.WithLabelValues(route).Inc()
+		return noRelease, false
+	}
+}
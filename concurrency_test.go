@@ -0,0 +1,100 @@
+package muxprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newBlockingRouter returns a router whose "slow" route signals entered and
+// blocks until unblock is closed.
+func newBlockingRouter(t *testing.T, options ...func(*MuxProm)) (*mux.Router, *MuxProm, chan struct{}, chan struct{}) {
+	t.Helper()
+	router := mux.NewRouter()
+	entered, unblock := make(chan struct{}, 10), make(chan struct{})
+	router.Name("slow").Path("/slow").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+	})
+	prom, err := New(append([]func(*MuxProm){Router(router), Registry(prometheus.NewRegistry())}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prom.Instrument(); err != nil {
+		t.Fatal(err)
+	}
+	return router, prom, entered, unblock
+}
+
+// serveAsync serves a request in the background and returns its status.
+func serveAsync(router http.Handler, path string) <-chan int {
+	done := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		done <- rec.Code
+	}()
+	return done
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		queue    int
+		disabled bool
+		want     []int
+		waited   float64
+	}{
+		{name: "no queue", want: []int{http.StatusOK, http.StatusServiceUnavailable}},
+		{name: "no queue while disabled", disabled: true, want: []int{http.StatusOK, http.StatusServiceUnavailable}},
+		{name: "queue", queue: 1, want: []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable}, waited: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, prom, entered, unblock := newBlockingRouter(t, ConcurrencyLimit("slow", 1, tt.queue))
+			if tt.disabled {
+				prom.Disable()
+			}
+
+			first := serveAsync(router, "/slow")
+			<-entered
+			var queued <-chan int
+			if tt.queue > 0 {
+				queued = serveAsync(router, "/slow")
+				for deadline := time.Now().Add(5 * time.Second); metricValue(t, prom, "http_request_queue_depth", "route", "slow") != 1; {
+					if time.Now().After(deadline) {
+						t.Fatal("request not queued")
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+			close(unblock)
+
+			got := []int{<-first}
+			if queued != nil {
+				got = append(got, <-queued)
+			}
+			got = append(got, rec.Code)
+			for i, want := range tt.want {
+				if got[i] != want {
+					t.Errorf("request %d: status %d, want %d", i, got[i], want)
+				}
+			}
+			if v := metricValue(t, prom, "http_request_queue_rejected_total", "route", "slow"); v != 1 {
+				t.Errorf("rejected %v, want 1", v)
+			}
+			if v := metricValue(t, prom, "http_request_queue_wait_seconds", "route", "slow"); v != tt.waited {
+				t.Errorf("queue waits %v, want %v", v, tt.waited)
+			}
+			if v := metricValue(t, prom, "http_request_queue_depth", "route", "slow"); v != 0 {
+				t.Errorf("queue depth %v, want 0", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package muxprom
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type conditionalRequests struct {
+	conditional *prometheus.CounterVec
+	notModified *prometheus.CounterVec
+}
+
+func newConditionalRequests(prom *MuxProm) *conditionalRequests {
+	return &conditionalRequests{
+		conditional: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_conditional_requests_total",
+				Help:      "HTTP requests carrying If-None-Match or If-Modified-Since by route",
+			},
+			[]string{"route", "method"},
+		),
+		notModified: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_responses_not_modified_total",
+				Help:      "HTTP 304 Not Modified responses by route",
+			},
+			[]string{"route", "method"},
+		),
+	}
+}
+
+func (c *conditionalRequests) observe(r *http.Request, route string, status int) {
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		c.conditional.WithLabelValues(route, r.Method).Inc()
+	}
+	if status == http.StatusNotModified {
+		c.notModified.WithLabelValues(route, r.Method).Inc()
+	}
+}
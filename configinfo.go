@@ -0,0 +1,52 @@
+package muxprom
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (prom *MuxProm) routeLabelingMode() string {
+	switch {
+	case prom.RouteLabeler != nil:
+		return "custom"
+	case prom.Router == nil && prom.ServeMux != nil:
+		return "servemux_pattern"
+	case prom.SchemaVersion >= SchemaV2:
+		return "mux_route_template"
+	}
+	return "mux_route_name"
+}
+
+func bucketSetHash(buckets ...[]float64) string {
+	h := fnv.New64a()
+	for _, b := range buckets {
+		fmt.Fprintf(h, "%v;", b)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func (prom *MuxProm) newConfigInfo() prometheus.Gauge {
+	labels := prometheus.Labels{
+		"namespace":      prom.Namespace,
+		"metrics_path":   prom.MetricsPath,
+		"buckets_hash":   bucketSetHash(prom.DurationBucket, prom.RespSizeBucket),
+		"route_labeling": prom.routeLabelingMode(),
+		"schema":         strconv.Itoa(prom.SchemaVersion),
+	}
+	for name, value := range prom.InfoLabels {
+		if _, ok := labels[name]; !ok {
+			labels[name] = value
+		}
+	}
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   prom.Namespace,
+		Name:        "config_info",
+		Help:        "Active muxprom configuration",
+		ConstLabels: labels,
+	})
+	g.Set(1)
+	return g
+}
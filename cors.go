@@ -0,0 +1,104 @@
+package muxprom
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type corsHandler struct {
+	cfg       CORSConfig
+	origins   map[string]bool
+	anyOrigin bool
+	methods   map[string]bool
+	requests  *prometheus.CounterVec
+}
+
+func newCORSHandler(prom *MuxProm) *corsHandler {
+	c := &corsHandler{
+		cfg:     *prom.CORS,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "cors_requests_total",
+				Help:      "Cross-origin HTTP requests by route and decision",
+			},
+			[]string{"route", "decision"},
+		),
+	}
+	if len(c.cfg.AllowedMethods) == 0 {
+		c.cfg.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	for _, o := range c.cfg.AllowedOrigins {
+		if o == "*" {
+			c.anyOrigin = true
+		}
+		c.origins[strings.ToLower(o)] = true
+	}
+	for _, m := range c.cfg.AllowedMethods {
+		c.methods[strings.ToUpper(m)] = true
+	}
+	return c
+}
+
+// handle applies CORS to the request and reports whether the handler should
+// still run; preflight requests are answered here.
+func (c *corsHandler) handle(w http.ResponseWriter, r *http.Request, route string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	w.Header().Add("Vary", "Origin")
+
+	allowed := c.anyOrigin || c.origins[strings.ToLower(origin)]
+	if allowed && preflight {
+		allowed = c.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))]
+	}
+	if !allowed {
+		c.requests.WithLabelValues(route, "rejected").Inc()
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	c.requests.WithLabelValues(route, "allowed").Inc()
+
+	h := w.Header()
+	if c.anyOrigin && !c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		return true
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(c.cfg.AllowedMethods, ", "))
+	if len(c.cfg.AllowedHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.cfg.AllowedHeaders, ", "))
+	} else if req := r.Header.Get("Access-Control-Request-Headers"); req != "" {
+		h.Set("Access-Control-Allow-Headers", req)
+	}
+	if c.cfg.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.cfg.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return false
+}
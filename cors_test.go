@@ -0,0 +1,69 @@
+package muxprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization"},
+		MaxAge:         10 * time.Minute,
+	}
+	tests := []struct {
+		name      string
+		method    string
+		origin    string
+		preflight string
+		want      int
+		header    map[string]string
+		allowed   float64
+		rejected  float64
+	}{
+		{name: "same origin", method: "GET", want: http.StatusOK},
+		{name: "allowed", method: "GET", origin: "https://app.example.com", want: http.StatusOK,
+			header: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"}, allowed: 1},
+		{name: "rejected", method: "GET", origin: "https://evil.example.com", want: http.StatusOK,
+			header: map[string]string{"Access-Control-Allow-Origin": ""}, rejected: 1},
+		{name: "preflight", method: "OPTIONS", origin: "https://app.example.com", preflight: "POST", want: http.StatusNoContent,
+			header: map[string]string{
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Authorization",
+				"Access-Control-Max-Age":       "600",
+			}, allowed: 1},
+		{name: "preflight for disallowed method", method: "OPTIONS", origin: "https://app.example.com", preflight: "DELETE", want: http.StatusForbidden,
+			rejected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, prom := newPolicyRouter(t, CORS(cfg))
+			r := httptest.NewRequest(tt.method, "/users/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.preflight)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+			for k, v := range tt.header {
+				if got := rec.Header().Get(k); got != v {
+					t.Errorf("%s %q, want %q", k, got, v)
+				}
+			}
+			if got := metricValue(t, prom, "cors_requests_total", "route", "Users", "decision", "allowed"); got != tt.allowed {
+				t.Errorf("allowed %v, want %v", got, tt.allowed)
+			}
+			if got := metricValue(t, prom, "cors_requests_total", "route", "Users", "decision", "rejected"); got != tt.rejected {
+				t.Errorf("rejected %v, want %v", got, tt.rejected)
+			}
+		})
+	}
+}
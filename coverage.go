@@ -0,0 +1,72 @@
+package muxprom
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+type Coverage struct {
+	Registered []string
+	Hit        []string
+	NeverHit   []string
+}
+
+type routeHits struct {
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+func (h *routeHits) record(r *http.Request) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return
+	}
+	name := route.GetName()
+	h.mu.RLock()
+	_, ok := h.seen[name]
+	h.mu.RUnlock()
+	if ok {
+		return
+	}
+	h.mu.Lock()
+	h.seen[name] = struct{}{}
+	h.mu.Unlock()
+}
+
+func (h *routeHits) hit(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.seen[name]
+	return ok
+}
+
+func (prom *MuxProm) Coverage() Coverage {
+	var c Coverage
+	if prom.Router == nil {
+		return c
+	}
+	seen := make(map[string]bool)
+	routers.Lock()
+	defer routers.Unlock()
+	prom.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		name := route.GetName()
+		if name == "" || seen[name] || prom.isOwnRouteName(name) || route.GetHandler() == nil {
+			return nil
+		}
+		seen[name] = true
+		c.Registered = append(c.Registered, name)
+		if prom.hits.hit(name) {
+			c.Hit = append(c.Hit, name)
+		} else {
+			c.NeverHit = append(c.NeverHit, name)
+		}
+		return nil
+	})
+	sort.Strings(c.Registered)
+	sort.Strings(c.Hit)
+	sort.Strings(c.NeverHit)
+	return c
+}
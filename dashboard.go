@@ -0,0 +1,203 @@
+package muxprom
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/mux"
+)
+
+type DashboardConfig struct {
+	Title          string
+	Namespace      string
+	SchemaVersion  int
+	Routes         []string
+	DurationBucket []float64
+	BucketsByRoute map[string][]float64
+}
+
+type dashboardPanel map[string]interface{}
+
+func (prom *MuxProm) Dashboard(title string) ([]byte, error) {
+	cfg := DashboardConfig{
+		Title:          title,
+		Namespace:      prom.Namespace,
+		SchemaVersion:  prom.SchemaVersion,
+		DurationBucket: prom.DurationBucket,
+		BucketsByRoute: prom.BucketsByRoute,
+	}
+	if prom.Router != nil {
+		var labels []string
+		routers.Lock()
+		err := prom.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			name := route.GetName()
+			if route.GetHandler() == nil || prom.isOwnRouteName(name) {
+				return nil
+			}
+			// Same labels as MuxRouteLabeler and MuxRouteTemplateLabeler;
+			// unnamed routes only have one with SchemaV2.
+			label := name
+			if prom.SchemaVersion >= SchemaV2 {
+				if tpl, err := route.GetPathTemplate(); err == nil && tpl != "" {
+					label = tpl
+				}
+			}
+			if label != "" {
+				labels = append(labels, label)
+			}
+			return nil
+		})
+		routers.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		prom.mu.RLock()
+		for _, label := range labels {
+			label = prom.normalizeRouteLabel(label)
+			if !seen[label] {
+				seen[label] = true
+				cfg.Routes = append(cfg.Routes, label)
+			}
+		}
+		prom.mu.RUnlock()
+	}
+	return GenerateDashboard(cfg)
+}
+
+// dashboardRefID names the n-th query of a panel: A to Z, then AA, AB, ...
+func dashboardRefID(n int) string {
+	if n < 26 {
+		return string(rune('A' + n))
+	}
+	return dashboardRefID(n/26-1) + string(rune('A'+n%26))
+}
+
+func GenerateDashboard(cfg DashboardConfig) ([]byte, error) {
+	if cfg.Namespace == "" {
+		cfg.Namespace = defaultNamespace
+	}
+	if cfg.Title == "" {
+		cfg.Title = cfg.Namespace
+	}
+	if cfg.SchemaVersion == 0 {
+		cfg.SchemaVersion = SchemaV1
+	}
+	if cfg.DurationBucket == nil {
+		cfg.DurationBucket = defaultDurationBucket
+	}
+	duration := cfg.Namespace + "_http_request_duration_seconds"
+	size := cfg.Namespace + "_" + sizeMetricName(cfg.SchemaVersion, "http_response_size")
+	inflight := cfg.Namespace + "_http_requests_inflight"
+
+	var panels []dashboardPanel
+	id := 1
+	y := 0
+	panel := func(title string, typ string, unit string, x int, w int, targets ...map[string]interface{}) dashboardPanel {
+		p := dashboardPanel{
+			"id":         id,
+			"title":      title,
+			"type":       typ,
+			"datasource": "${DS_PROMETHEUS}",
+			"gridPos":    map[string]int{"h": 8, "w": w, "x": x, "y": y},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": unit},
+				"overrides": []interface{}{},
+			},
+			"targets": targets,
+		}
+		id++
+		return p
+	}
+	target := func(refID string, expr string, legend string) map[string]interface{} {
+		return map[string]interface{}{"refId": refID, "expr": expr, "legendFormat": legend}
+	}
+
+	panels = append(panels,
+		panel("Requests per second", "timeseries", "reqps", 0, 8,
+			target("A", fmt.Sprintf(`sum(rate(%s_count{instance="$instance"}[$__rate_interval])) by (route)`, duration), "{{route}}")),
+		panel("Error rate (5xx)", "timeseries", "percentunit", 8, 8,
+			target("A", fmt.Sprintf(`sum(rate(%[1]s_count{instance="$instance",http_status=~"5.."}[$__rate_interval])) by (route) / sum(rate(%[1]s_count{instance="$instance"}[$__rate_interval])) by (route)`, duration), "{{route}}")),
+		panel("In-flight requests", "timeseries", "short", 16, 8,
+			target("A", fmt.Sprintf(`sum(%s{instance="$instance"}) by (route)`, inflight), "{{route}}")),
+	)
+	y += 8
+
+	for _, route := range cfg.Routes {
+		sel := fmt.Sprintf(`instance="$instance",route=%q`, route)
+		panels = append(panels, dashboardPanel{
+			"id":        id,
+			"type":      "row",
+			"title":     route,
+			"collapsed": false,
+			"gridPos":   map[string]int{"h": 1, "w": 24, "x": 0, "y": y},
+			"panels":    []interface{}{},
+		})
+		id++
+		y++
+
+		latency := func(q string) string {
+			return fmt.Sprintf(`histogram_quantile(%s, sum(rate(%s_bucket{%s}[$__rate_interval])) by (le))`, q, duration, sel)
+		}
+		sizeQuantile := func(q string) string {
+			return fmt.Sprintf(`histogram_quantile(%s, sum(rate(%s_bucket{%s}[$__rate_interval])) by (le))`, q, size, sel)
+		}
+		heatmap := panel("Latency distribution", "heatmap", "s", 6, 6,
+			target("A", fmt.Sprintf(`sum(increase(%s_bucket{%s}[$__rate_interval])) by (le)`, duration, sel), "{{le}}"))
+		heatmap["targets"].([]map[string]interface{})[0]["format"] = "heatmap"
+		panels = append(panels,
+			panel("Latency", "timeseries", "s", 0, 6,
+				target("A", latency("0.5"), "p50"),
+				target("B", latency("0.95"), "p95"),
+				target("C", latency("0.99"), "p99")),
+			heatmap,
+			panel("Error rate", "timeseries", "percentunit", 12, 6,
+				target("A", fmt.Sprintf(`sum(rate(%[1]s_count{%[2]s,http_status=~"5.."}[$__rate_interval])) / sum(rate(%[1]s_count{%[2]s}[$__rate_interval]))`, duration, sel), "5xx"),
+				target("B", fmt.Sprintf(`sum(rate(%[1]s_count{%[2]s,http_status=~"4.."}[$__rate_interval])) / sum(rate(%[1]s_count{%[2]s}[$__rate_interval]))`, duration, sel), "4xx")),
+			panel("Response size", "timeseries", "bytes", 18, 6,
+				target("A", sizeQuantile("0.5"), "p50"),
+				target("B", sizeQuantile("0.95"), "p95")),
+		)
+		y += 8
+
+		buckets, ok := cfg.BucketsByRoute[route]
+		if !ok {
+			buckets = cfg.DurationBucket
+		}
+		var within []map[string]interface{}
+		for i, le := range buckets {
+			within = append(within, target(dashboardRefID(i),
+				fmt.Sprintf(`sum(rate(%[1]s_bucket{%[2]s,le="%[3]s"}[$__rate_interval])) / sum(rate(%[1]s_count{%[2]s}[$__rate_interval]))`, duration, sel, formatFloat(le)),
+				"≤ "+formatFloat(le)+"s"))
+		}
+		panels = append(panels, panel("Requests within latency buckets", "bargauge", "percentunit", 0, 24, within...))
+		y += 8
+	}
+
+	dashboard := map[string]interface{}{
+		"__inputs": []map[string]string{{
+			"name":       "DS_PROMETHEUS",
+			"label":      "Prometheus",
+			"type":       "datasource",
+			"pluginId":   "prometheus",
+			"pluginName": "Prometheus",
+		}},
+		"title":         cfg.Title,
+		"editable":      true,
+		"schemaVersion": 36,
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{{
+				"name":       "instance",
+				"type":       "query",
+				"datasource": "${DS_PROMETHEUS}",
+				"query":      fmt.Sprintf("label_values(%s, instance)", inflight),
+				"definition": fmt.Sprintf("label_values(%s, instance)", inflight),
+				"refresh":    2,
+			}},
+		},
+		"panels": panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
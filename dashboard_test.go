@@ -0,0 +1,83 @@
+package muxprom
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDashboard(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*MuxProm)
+		rows    []string
+		exprs   []string
+	}{
+		{
+			name:  "v1",
+			rows:  []string{"users"},
+			exprs: []string{`le="0.0001"`, `le="10"`},
+		},
+		{
+			name:    "v2 with unnamed route",
+			options: []func(*MuxProm){SchemaVersion(SchemaV2)},
+			rows:    []string{"/users/{id}", "/health"},
+			exprs:   []string{"muxprom_http_response_size_bytes_bucket"},
+		},
+		{
+			name:    "route buckets",
+			options: []func(*MuxProm){BucketsByRoute(map[string][]float64{"users": {1, 5, 30}})},
+			rows:    []string{"users"},
+			exprs:   []string{`route="users",le="30"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			noop := func(w http.ResponseWriter, r *http.Request) {}
+			router.Name("users").Path("/users/{id}").HandlerFunc(noop)
+			router.Path("/health").HandlerFunc(noop)
+			prom, err := New(append([]func(*MuxProm){Router(router), Registry(prometheus.NewRegistry())}, tt.options...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := prom.Dashboard("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var dashboard struct {
+				Panels []struct {
+					Type    string
+					Title   string
+					Targets []struct{ Expr string }
+				}
+			}
+			if err := json.Unmarshal(b, &dashboard); err != nil {
+				t.Fatal(err)
+			}
+			var rows []string
+			var exprs strings.Builder
+			for _, p := range dashboard.Panels {
+				if p.Type == "row" {
+					rows = append(rows, p.Title)
+				}
+				for _, target := range p.Targets {
+					exprs.WriteString(target.Expr + "\n")
+				}
+			}
+			if strings.Join(rows, ",") != strings.Join(tt.rows, ",") {
+				t.Errorf("rows %v, want %v", rows, tt.rows)
+			}
+			for _, expr := range tt.exprs {
+				if !strings.Contains(exprs.String(), expr) {
+					t.Errorf("no query contains %s", expr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package muxprom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+type DeltaPushConfig struct {
+	URL      string
+	Interval time.Duration
+	Timeout  time.Duration
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+type deltaPusher struct {
+	mu   sync.Mutex
+	prev *restoredState
+}
+
+func (prom *MuxProm) StartDeltaPush(cfg DeltaPushConfig) (stop func() error) {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	// Totals restored from StateFile were pushed before the restart, so
+	// they are the baseline rather than the first delta.
+	p := &deltaPusher{prev: newRestoredState()}
+	if prom.restored != nil {
+		p.prev = prom.restored
+	}
+	push := func() error {
+		return p.push(prom, cfg)
+	}
+	return startPeriodic(prom.Logger, cfg.Interval, "delta push to "+cfg.URL, push, push)
+}
+
+func (p *deltaPusher) push(prom *MuxProm, cfg DeltaPushConfig) error {
+	mfs, err := prom.gatherer().Gather()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := newRestoredState()
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if !persistable(mf) {
+			continue
+		}
+		current.add(mf)
+		delta := &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}
+		for _, m := range mf.Metric {
+			if d := deltaMetric(m, p.prev.metrics[mf.GetName()][labelKey(m.Label)]); d != nil {
+				delta.Metric = append(delta.Metric, d)
+			}
+		}
+		if len(delta.Metric) == 0 {
+			continue
+		}
+		if _, err := expfmt.MetricFamilyToText(&buf, delta); err != nil {
+			return err
+		}
+	}
+	if buf.Len() > 0 {
+		if err := postDeltas(cfg, buf.Bytes()); err != nil {
+			// prev stays as is, so the next push carries these deltas too.
+			return err
+		}
+	}
+	p.prev = current
+	return nil
+}
+
+// deltaMetric returns the increase of m since prev, or nil if there is none.
+// A value below prev means the process restarted counting.
+func deltaMetric(m *dto.Metric, prev *dto.Metric) *dto.Metric {
+	if m.Counter != nil {
+		v := m.Counter.GetValue()
+		if prev != nil && prev.Counter != nil && v >= prev.Counter.GetValue() {
+			v -= prev.Counter.GetValue()
+		}
+		if v == 0 {
+			return nil
+		}
+		return &dto.Metric{Label: m.Label, Counter: &dto.Counter{Value: &v}}
+	}
+	if m.Histogram == nil {
+		return nil
+	}
+	count := m.Histogram.GetSampleCount()
+	sum := m.Histogram.GetSampleSum()
+	buckets := make([]*dto.Bucket, len(m.Histogram.Bucket))
+	for i, b := range m.Histogram.Bucket {
+		buckets[i] = &dto.Bucket{UpperBound: b.UpperBound, CumulativeCount: b.CumulativeCount}
+	}
+	if prev != nil && prev.Histogram != nil && sameBuckets(m.Histogram, prev.Histogram) && count >= prev.Histogram.GetSampleCount() {
+		count -= prev.Histogram.GetSampleCount()
+		sum -= prev.Histogram.GetSampleSum()
+		for i, b := range buckets {
+			c := b.GetCumulativeCount() - prev.Histogram.Bucket[i].GetCumulativeCount()
+			b.CumulativeCount = &c
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	return &dto.Metric{Label: m.Label, Histogram: &dto.Histogram{SampleCount: &count, SampleSum: &sum, Bucket: buckets}}
+}
+
+func postDeltas(cfg DeltaPushConfig, body []byte) error {
+	req, err := http.NewRequest("POST", cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(expfmt.FmtText))
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+type DeltaAggregator struct {
+	mu    sync.Mutex
+	state *restoredState
+}
+
+func NewDeltaAggregator() *DeltaAggregator {
+	return &DeltaAggregator{state: newRestoredState()}
+}
+
+func (a *DeltaAggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		deltas, err := parseState(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.mu.Lock()
+		for _, mf := range deltas.families {
+			a.state.add(mf)
+		}
+		a.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet, http.MethodHead:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		names := make([]string, 0, len(a.state.families))
+		for name := range a.state.families {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		for _, name := range names {
+			if _, err := expfmt.MetricFamilyToText(w, a.state.families[name]); err != nil {
+				return
+			}
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
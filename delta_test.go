@@ -0,0 +1,87 @@
+package muxprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeltaPush(t *testing.T) {
+	tests := []struct {
+		name      string
+		stateFile bool
+		runs      []int
+		want      uint64
+	}{
+		{name: "single run", runs: []int{2}, want: 2},
+		{name: "restart", runs: []int{2, 3}, want: 5},
+		{name: "restart from state file", stateFile: true, runs: []int{2, 3}, want: 5},
+		{name: "restart from state file without requests", stateFile: true, runs: []int{2, 0, 1}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator := httptest.NewServer(NewDeltaAggregator())
+			defer aggregator.Close()
+			var options []func(*MuxProm)
+			if tt.stateFile {
+				options = append(options, StateFile(filepath.Join(t.TempDir(), "state.prom")))
+			}
+
+			for i, requests := range tt.runs {
+				prom := serveRun(t, requests, options...)
+				stop := prom.StartDeltaPush(DeltaPushConfig{URL: aggregator.URL, Interval: time.Hour})
+				if err := stop(); err != nil {
+					t.Fatalf("run %d: %v", i, err)
+				}
+				if err := prom.Close(); err != nil {
+					t.Fatalf("run %d: %v", i, err)
+				}
+			}
+
+			resp, err := http.Get(aggregator.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := requestCount(t, resp.Body); got != tt.want {
+				t.Errorf("aggregated %d requests, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeltaPushRetriesFailedDeltas(t *testing.T) {
+	aggregator := NewDeltaAggregator()
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail && r.Method == http.MethodPost {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		aggregator.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	prom := serveRun(t, 2)
+	defer prom.Close()
+	cfg := DeltaPushConfig{URL: server.URL, Client: server.Client()}
+	p := &deltaPusher{prev: newRestoredState()}
+	if err := p.push(prom, cfg); err == nil {
+		t.Fatal("push to a failing aggregator succeeded")
+	}
+	fail = false
+	if err := p.push(prom, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.push(prom, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	aggregator.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := requestCount(t, rec.Body); got != 2 {
+		t.Errorf("aggregated %d requests, want 2", got)
+	}
+}
@@ -0,0 +1,55 @@
+package muxprom
+
+import (
+	"os"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func DeploymentLabels() prometheus.Labels {
+	labels := prometheus.Labels{}
+	hostname := os.Getenv("HOSTNAME")
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	setLabel(labels, "hostname", hostname)
+	setLabel(labels, "pod", os.Getenv("POD_NAME"))
+	setLabel(labels, "deploy_color", os.Getenv("DEPLOY_COLOR"))
+	sha := os.Getenv("GIT_SHA")
+	if sha == "" {
+		sha = buildRevision()
+	}
+	setLabel(labels, "git_sha", sha)
+	return labels
+}
+
+func setLabel(labels prometheus.Labels, name string, value string) {
+	if value != "" {
+		labels[name] = value
+	}
+}
+
+func buildRevision() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range bi.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// wrapRegisterer adds the const labels to everything registered through reg.
+// The registry unwraps AlreadyRegisteredError.ExistingCollector, so adopting
+// an already registered MuxProm keeps working.
+func (prom *MuxProm) wrapRegisterer(reg prometheus.Registerer) prometheus.Registerer {
+	labels := prom.constLabels()
+	if len(labels) == 0 {
+		return reg
+	}
+	return prometheus.WrapRegistererWith(labels, reg)
+}
@@ -0,0 +1,108 @@
+package muxprom
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var dogStatsDTagReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+
+type DogStatsDRecorder struct {
+	conn   net.Conn
+	prefix string
+
+	mu        sync.Mutex
+	inflight  map[[2]string]int64
+	sizeNames []string
+}
+
+func NewDogStatsDRecorder(addr string, prefix string) (*DogStatsDRecorder, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &DogStatsDRecorder{
+		conn:      conn,
+		prefix:    prefix,
+		inflight:  make(map[[2]string]int64),
+		sizeNames: []string{sizeMetricName(SchemaV1, "http_response_size")},
+	}, nil
+}
+
+// useSchema names the size metric after the schema of the MuxProm the
+// recorder is given to, like the Prometheus histograms.
+func (d *DogStatsDRecorder) useSchema(schema int, legacy bool) {
+	names := []string{sizeMetricName(schema, "http_response_size")}
+	if legacy && schema >= SchemaV2 {
+		names = append(names, "http_response_size")
+	}
+	d.mu.Lock()
+	d.sizeNames = names
+	d.mu.Unlock()
+}
+
+func (d *DogStatsDRecorder) Close() error {
+	return d.conn.Close()
+}
+
+func (d *DogStatsDRecorder) IncInflight(route string, method string) {
+	d.addInflight(route, method, 1)
+}
+
+func (d *DogStatsDRecorder) DecInflight(route string, method string) {
+	d.addInflight(route, method, -1)
+}
+
+func (d *DogStatsDRecorder) addInflight(route string, method string, delta int64) {
+	key := [2]string{route, method}
+	// The gauge is sent under the lock so that concurrent updates reach the
+	// agent in the order they were applied.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inflight[key] += delta
+	n := d.inflight[key]
+	if n == 0 {
+		delete(d.inflight, key)
+	}
+	d.send("http_requests_inflight", strconv.FormatInt(n, 10), "g", route, method, "")
+}
+
+func (d *DogStatsDRecorder) ObserveDuration(ctx context.Context, route string, method string, status int, duration time.Duration) {
+	d.send("http_request_duration_seconds", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64), "h", route, method, strconv.Itoa(status))
+}
+
+func (d *DogStatsDRecorder) ObserveSize(ctx context.Context, route string, method string, status int, bytes int) {
+	d.mu.Lock()
+	names := d.sizeNames
+	d.mu.Unlock()
+	for _, name := range names {
+		d.send(name, strconv.Itoa(bytes), "h", route, method, strconv.Itoa(status))
+	}
+}
+
+func (d *DogStatsDRecorder) send(name string, value string, typ string, route string, method string, status string) {
+	var b strings.Builder
+	b.WriteString(d.prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(typ)
+	b.WriteString("|#route:")
+	b.WriteString(dogStatsDTagReplacer.Replace(route))
+	b.WriteString(",method:")
+	b.WriteString(dogStatsDTagReplacer.Replace(method))
+	if status != "" {
+		b.WriteString(",http_status:")
+		b.WriteString(status)
+	}
+	// Errors are ignored: like any statsd client, emitting is best effort.
+	d.conn.Write([]byte(b.String()))
+}
@@ -0,0 +1,75 @@
+package muxprom
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDogStatsDSizeMetricName(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*MuxProm)
+		want    []string
+	}{
+		{name: "v1", want: []string{"myapp.http_response_size"}},
+		{name: "v2", options: []func(*MuxProm){SchemaVersion(SchemaV2)}, want: []string{"myapp.http_response_size_bytes"}},
+		{
+			name:    "v2 with legacy names",
+			options: []func(*MuxProm){SchemaVersion(SchemaV2), LegacyMetricNames(true)},
+			want:    []string{"myapp.http_response_size", "myapp.http_response_size_bytes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			dd, err := NewDogStatsDRecorder(conn.LocalAddr().String(), "myapp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dd.Close()
+
+			router := mux.NewRouter()
+			router.Name("hello").Path("/hello").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			})
+			prom, err := New(append([]func(*MuxProm){Router(router), Registry(prometheus.NewRegistry()), WithRecorders(dd)}, tt.options...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := prom.Instrument(); err != nil {
+				t.Fatal(err)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+
+			var got []string
+			buf := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					break
+				}
+				if name, _, _ := strings.Cut(string(buf[:n]), ":"); strings.Contains(name, "size") {
+					got = append(got, name)
+				}
+				conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("size metrics %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
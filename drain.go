@@ -0,0 +1,34 @@
+package muxprom
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var drainPollInterval = 10 * time.Millisecond
+
+func newDrainingGauge(prom *MuxProm) prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: prom.Namespace,
+		Name:      "draining",
+		Help:      "1 while Drain is waiting for in-flight requests to finish",
+	})
+}
+
+func (prom *MuxProm) Drain(ctx context.Context) error {
+	prom.draining.Inc()
+	defer prom.draining.Dec()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for prom.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package echoprom
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rusart/muxprom"
+)
+
+type routeKey struct{}
+
+func routeLabeler(r *http.Request) string {
+	if route, ok := r.Context().Value(routeKey{}).(string); ok && route != "" {
+		return route
+	}
+	return r.RequestURI
+}
+
+func New(e *echo.Echo, options ...func(prom *muxprom.MuxProm)) (*muxprom.MuxProm, error) {
+	prom, err := muxprom.New(append([]func(*muxprom.MuxProm){muxprom.RouteLabeler(routeLabeler)}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+	e.GET(prom.MetricsPath, echo.WrapHandler(prom.Handler()))
+	return prom, nil
+}
+
+func Middleware(prom muxprom.Instrumenter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			writer := c.Response().Writer
+			defer func() { c.Response().Writer = writer }()
+			h := prom.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				c.Response().Writer = w
+				if err := next(c); err != nil {
+					c.Error(err)
+				}
+			}))
+			r := c.Request()
+			h.ServeHTTP(writer, r.WithContext(context.WithValue(r.Context(), routeKey{}, c.Path())))
+			return nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package muxprom
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var emfMaxValues = 100
+
+type emfKey struct {
+	route  string
+	method string
+	status int
+}
+
+type emfAggregate struct {
+	count     int
+	bytes     int
+	durations []float64
+}
+
+type EMFRecorder struct {
+	// Logger receives the write failures of Start. Default: slog.Default()
+	Logger Logger
+
+	w         io.Writer
+	namespace string
+
+	mu         sync.Mutex
+	aggregates map[emfKey]*emfAggregate
+}
+
+func NewEMFRecorder(w io.Writer, namespace string) *EMFRecorder {
+	return &EMFRecorder{
+		Logger:     defaultLogger(),
+		w:          w,
+		namespace:  namespace,
+		aggregates: make(map[emfKey]*emfAggregate),
+	}
+}
+
+func (e *EMFRecorder) IncInflight(route string, method string) {}
+
+func (e *EMFRecorder) DecInflight(route string, method string) {}
+
+func (e *EMFRecorder) ObserveDuration(ctx context.Context, route string, method string, status int, d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	agg := e.aggregate(route, method, status)
+	agg.count++
+	// Reservoir sampling keeps at most emfMaxValues values, the EMF limit per metric.
+	if len(agg.durations) < emfMaxValues {
+		agg.durations = append(agg.durations, d.Seconds())
+	} else if i := rand.Intn(agg.count); i < emfMaxValues {
+		agg.durations[i] = d.Seconds()
+	}
+}
+
+func (e *EMFRecorder) ObserveSize(ctx context.Context, route string, method string, status int, bytes int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.aggregate(route, method, status).bytes += bytes
+}
+
+func (e *EMFRecorder) aggregate(route string, method string, status int) *emfAggregate {
+	key := emfKey{route: route, method: method, status: status}
+	agg, ok := e.aggregates[key]
+	if !ok {
+		agg = &emfAggregate{}
+		e.aggregates[key] = agg
+	}
+	return agg
+}
+
+func (e *EMFRecorder) Flush() error {
+	e.mu.Lock()
+	aggregates := e.aggregates
+	e.aggregates = make(map[emfKey]*emfAggregate)
+	e.mu.Unlock()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	enc := json.NewEncoder(e.w)
+	for key, agg := range aggregates {
+		line := map[string]interface{}{
+			"_aws": map[string]interface{}{
+				"Timestamp": now,
+				"CloudWatchMetrics": []interface{}{
+					map[string]interface{}{
+						"Namespace":  e.namespace,
+						"Dimensions": [][]string{{"route", "method", "http_status"}},
+						"Metrics": []map[string]string{
+							{"Name": "RequestCount", "Unit": "Count"},
+							{"Name": "Duration", "Unit": "Seconds"},
+							{"Name": "ResponseSize", "Unit": "Bytes"},
+						},
+					},
+				},
+			},
+			"route":        key.route,
+			"method":       key.method,
+			"http_status":  strconv.Itoa(key.status),
+			"RequestCount": agg.count,
+			"Duration":     agg.durations,
+			"ResponseSize": agg.bytes,
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *EMFRecorder) Start(interval time.Duration) (stop func() error) {
+	logger := e.Logger
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	return startPeriodic(logger, interval, "writing EMF", e.Flush, e.Flush)
+}
@@ -0,0 +1,64 @@
+package muxprom
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var maxErrorClasses = 20
+
+type errorClasses struct {
+	classify func(*http.Request, int) string
+	errors   *prometheus.CounterVec
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func ClassifyByStatus(r *http.Request, status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "auth"
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status >= 500:
+		return "server"
+	case status >= 400:
+		return "client"
+	}
+	return ""
+}
+
+func newErrorClasses(prom *MuxProm) *errorClasses {
+	return &errorClasses{
+		classify: prom.ClassifyError,
+		seen:     make(map[string]struct{}),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_errors_total",
+				Help:      "HTTP request errors by error class",
+			},
+			[]string{"route", "method", "error_class"},
+		),
+	}
+}
+
+func (e *errorClasses) observe(r *http.Request, route string, status int) {
+	class := e.classify(r, status)
+	if class == "" {
+		return
+	}
+	e.mu.Lock()
+	if _, ok := e.seen[class]; !ok {
+		if len(e.seen) >= maxErrorClasses {
+			class = "other"
+		} else {
+			e.seen[class] = struct{}{}
+		}
+	}
+	e.mu.Unlock()
+	e.errors.WithLabelValues(route, r.Method, class).Inc()
+}
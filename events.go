@@ -0,0 +1,43 @@
+package muxprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type RequestEvent struct {
+	Time time.Time
+	RouteInfo
+}
+
+type eventStream struct {
+	ch      chan RequestEvent
+	dropped prometheus.Counter
+}
+
+func (prom *MuxProm) Events() <-chan RequestEvent {
+	if prom.events == nil {
+		return nil
+	}
+	return prom.events.ch
+}
+
+func (s *eventStream) publish(ev RequestEvent) {
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+	// The buffer is full: drop the oldest event to make room.
+	select {
+	case <-s.ch:
+		s.dropped.Inc()
+	default:
+	}
+	select {
+	case s.ch <- ev:
+	default:
+		s.dropped.Inc()
+	}
+}
@@ -0,0 +1,77 @@
+package muxprom
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type exemplarKey struct{}
+
+func (prom *MuxProm) exemplarContext(r *http.Request, requestID string, duration time.Duration) context.Context {
+	ctx := r.Context()
+	if !prom.Exemplars || duration < prom.ExemplarMinDuration {
+		return ctx
+	}
+	if prom.ExemplarSampleRate > 1 && prom.exemplarCount.Add(1)%uint64(prom.ExemplarSampleRate) != 0 {
+		return ctx
+	}
+	labels := exemplarLabels(r)
+	if requestID != "" {
+		if labels == nil {
+			labels = prometheus.Labels{}
+		}
+		addRequestIDLabel(labels, requestID)
+	}
+	if len(labels) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, exemplarKey{}, labels)
+}
+
+func addRequestIDLabel(labels prometheus.Labels, requestID string) {
+	room := prometheus.ExemplarMaxRunes - len("request_id")
+	for name, value := range labels {
+		room -= utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	if room <= 0 {
+		return
+	}
+	if utf8.RuneCountInString(requestID) > room {
+		requestID = string([]rune(requestID)[:room])
+	}
+	labels["request_id"] = requestID
+}
+
+func exemplarLabels(r *http.Request) prometheus.Labels {
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		return prometheus.Labels{"trace_id": sc.TraceID().String(), "span_id": sc.SpanID().String()}
+	}
+	// W3C traceparent: version-traceid-parentid-flags
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return nil
+	}
+	if _, err := trace.TraceIDFromHex(parts[1]); err != nil {
+		return nil
+	}
+	if _, err := trace.SpanIDFromHex(parts[2]); err != nil {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": parts[1], "span_id": parts[2]}
+}
+
+func observeWithExemplar(ctx context.Context, o prometheus.Observer, v float64) {
+	if labels, ok := ctx.Value(exemplarKey{}).(prometheus.Labels); ok {
+		if eo, ok := o.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(v, labels)
+			return
+		}
+	}
+	o.Observe(v)
+}
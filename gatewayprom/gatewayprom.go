@@ -0,0 +1,30 @@
+package gatewayprom
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/rusart/muxprom"
+	"google.golang.org/grpc/metadata"
+)
+
+func WithPathPatternLabel() runtime.ServeMuxOption {
+	return runtime.WithMiddlewares(func(next runtime.HandlerFunc) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+				muxprom.SetRouteLabel(r.Context(), pattern.String())
+			}
+			next(w, r, pathParams)
+		}
+	})
+}
+
+func WithRPCMethodLabel() runtime.ServeMuxOption {
+	return runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+		if method, ok := runtime.RPCMethod(ctx); ok {
+			muxprom.SetRouteLabel(ctx, method)
+		}
+		return nil
+	})
+}
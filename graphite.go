@@ -0,0 +1,34 @@
+package muxprom
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/graphite"
+)
+
+func (prom *MuxProm) StartGraphite(url string, prefix string, interval time.Duration) (stop func(), err error) {
+	bridge, err := graphite.NewBridge(&graphite.Config{
+		URL:           url,
+		Gatherer:      prom.gatherer(),
+		Prefix:        prefix,
+		Interval:      interval,
+		Timeout:       interval,
+		Logger:        printlnLogger{logger: prom.Logger, msg: "muxprom: pushing to graphite failed"},
+		ErrorHandling: graphite.ContinueOnError,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		bridge.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-finished
+	}, nil
+}
@@ -0,0 +1,118 @@
+package muxprom
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var defaultGraphQLOperationLimit = 100
+
+// Larger request bodies are recorded as anonymous operations instead of
+// being buffered before the handler runs.
+var maxGraphQLBodySize = 64 << 10
+
+var graphqlOperationRegexp = regexp.MustCompile(`^\s*(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+type graphqlOperations struct {
+	routes   map[string]struct{}
+	limit    int
+	duration *prometheus.HistogramVec
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newGraphQLOperations(prom *MuxProm) *graphqlOperations {
+	g := &graphqlOperations{
+		routes: make(map[string]struct{}),
+		limit:  prom.GraphQLOperationLimit,
+		seen:   make(map[string]struct{}),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "graphql_operation_duration_seconds",
+				Help:      "GraphQL operation duration seconds",
+				Buckets:   prom.DurationBucket,
+			},
+			[]string{"route", "operation", "http_status"},
+		),
+	}
+	for _, route := range prom.GraphQLRoutes {
+		g.routes[route] = struct{}{}
+	}
+	return g
+}
+
+func (g *graphqlOperations) matches(route string) bool {
+	_, ok := g.routes[route]
+	return ok
+}
+
+func (g *graphqlOperations) operation(r *http.Request) string {
+	name := r.Header.Get("X-GraphQL-Operation")
+	if name == "" {
+		name = graphqlOperationName(r)
+	}
+	if name == "" {
+		return "anonymous"
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.seen[name]; ok {
+		return name
+	}
+	if len(g.seen) >= g.limit {
+		return "other"
+	}
+	g.seen[name] = struct{}{}
+	return name
+}
+
+func (g *graphqlOperations) observe(route string, operation string, status int, d time.Duration) {
+	g.duration.WithLabelValues(route, operation, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+func graphqlOperationName(r *http.Request) string {
+	var params struct {
+		OperationName string `json:"operationName"`
+		Query         string `json:"query"`
+	}
+	if r.Method == "GET" {
+		params.OperationName = r.URL.Query().Get("operationName")
+		params.Query = r.URL.Query().Get("query")
+	} else if r.Body != nil && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxGraphQLBodySize)+1))
+		r.Body = replayedBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+		if err != nil || len(body) > maxGraphQLBodySize {
+			return ""
+		}
+		if err := json.Unmarshal(body, &params); err != nil {
+			return ""
+		}
+	}
+	if params.OperationName != "" {
+		return params.OperationName
+	}
+	if m := graphqlOperationRegexp.FindStringSubmatch(params.Query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// replayedBody serves the bytes already read followed by the rest of the
+// original body.
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
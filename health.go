@@ -0,0 +1,49 @@
+package muxprom
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (prom *MuxProm) Healthy() error {
+	if prom.isClosed() {
+		return errors.New("muxprom: closed")
+	}
+	if prom.Registerer != nil {
+		reg := prom.wrapRegisterer(prom.Registerer)
+		err := reg.Register(prom)
+		if err == nil {
+			reg.Unregister(prom)
+			return errors.New("muxprom: collectors are not registered")
+		}
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) || are.ExistingCollector != prometheus.Collector(prom.registered) {
+			return fmt.Errorf("muxprom: collectors are not registered: %w", err)
+		}
+	}
+
+	if prom.metricsMount != nil && !prom.metricsMount.ownedBy(prom) {
+		return fmt.Errorf("muxprom: metrics route %q is not mounted", prom.MetricsRouteName)
+	}
+	if prom.Router != nil {
+		if prom.Router.Get(prom.MetricsRouteName) == nil {
+			return fmt.Errorf("muxprom: metrics route %q is not mounted", prom.MetricsRouteName)
+		}
+	} else if prom.ServeMux != nil {
+		r, err := http.NewRequest("GET", prom.MetricsPath, nil)
+		if err != nil {
+			return fmt.Errorf("muxprom: invalid metrics path %q: %w", prom.MetricsPath, err)
+		}
+		if _, pattern := prom.ServeMux.Handler(r); pattern != prom.MetricsPath {
+			return fmt.Errorf("muxprom: metrics path %q is not mounted", prom.MetricsPath)
+		}
+	}
+
+	if _, err := prom.gatherer().Gather(); err != nil {
+		return fmt.Errorf("muxprom: gathering metrics failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package muxprom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var influxMaxDatagramSize = 1400
+
+var influxKeyReplacer = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+var influxMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+type InfluxDBConfig struct {
+	URL      string
+	Interval time.Duration
+	Timeout  time.Duration
+	Token    string
+	Client   *http.Client
+}
+
+func (prom *MuxProm) StartInfluxDB(cfg InfluxDBConfig) (stop func() error) {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	write := func() error {
+		return prom.WriteInfluxDB(cfg)
+	}
+	return startPeriodic(prom.Logger, cfg.Interval, "writing to InfluxDB "+cfg.URL, write, write)
+}
+
+func (prom *MuxProm) WriteInfluxDB(cfg InfluxDBConfig) error {
+	mfs, err := prom.gatherer().Gather()
+	if err != nil {
+		return err
+	}
+	lines := influxLines(mfs, time.Now())
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "udp" {
+		return writeInfluxUDP(u.Host, cfg.Timeout, lines)
+	}
+	return writeInfluxHTTP(cfg, lines)
+}
+
+func writeInfluxUDP(addr string, timeout time.Duration, lines []string) error {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+len(line) > influxMaxDatagramSize {
+			if _, err := conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		_, err = conn.Write(buf.Bytes())
+	}
+	return err
+}
+
+func writeInfluxHTTP(cfg InfluxDBConfig, lines []string) error {
+	req, err := http.NewRequest("POST", cfg.URL, strings.NewReader(strings.Join(lines, "")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+cfg.Token)
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func influxLines(mfs []*dto.MetricFamily, now time.Time) []string {
+	var lines []string
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			ts := now.UnixNano()
+			if m.GetTimestampMs() != 0 {
+				ts = m.GetTimestampMs() * int64(time.Millisecond)
+			}
+
+			var fields []string
+			field := func(key string, value float64) {
+				if math.IsNaN(value) || math.IsInf(value, 0) {
+					return
+				}
+				fields = append(fields, influxKeyReplacer.Replace(key)+"="+strconv.FormatFloat(value, 'f', -1, 64))
+			}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				field("counter", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				field("gauge", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				field("value", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				field("count", float64(s.GetSampleCount()))
+				field("sum", s.GetSampleSum())
+				for _, q := range s.GetQuantile() {
+					field(formatFloat(q.GetQuantile()), q.GetValue())
+				}
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				field("count", float64(h.GetSampleCount()))
+				field("sum", h.GetSampleSum())
+				for _, b := range h.GetBucket() {
+					field(formatFloat(b.GetUpperBound()), float64(b.GetCumulativeCount()))
+				}
+				field("+Inf", float64(h.GetSampleCount()))
+			}
+			if len(fields) == 0 {
+				continue
+			}
+
+			var b strings.Builder
+			b.WriteString(influxMeasurementReplacer.Replace(mf.GetName()))
+			for _, lp := range m.GetLabel() {
+				if lp.GetValue() == "" {
+					continue
+				}
+				b.WriteByte(',')
+				b.WriteString(influxKeyReplacer.Replace(lp.GetName()))
+				b.WriteByte('=')
+				b.WriteString(influxKeyReplacer.Replace(lp.GetValue()))
+			}
+			b.WriteByte(' ')
+			b.WriteString(strings.Join(fields, ","))
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatInt(ts, 10))
+			b.WriteByte('\n')
+			lines = append(lines, b.String())
+		}
+	}
+	return lines
+}
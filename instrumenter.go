@@ -0,0 +1,15 @@
+package muxprom
+
+import "net/http"
+
+type Instrumenter interface {
+	Instrument() error
+	Middleware(next http.Handler) http.Handler
+	Handler() http.Handler
+	Close() error
+}
+
+var (
+	_ Instrumenter = (*MuxProm)(nil)
+	_ Instrumenter = (*NoopMuxProm)(nil)
+)
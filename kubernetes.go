@@ -0,0 +1,66 @@
+package muxprom
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultPodInfoDir       = "/etc/podinfo"
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+func KubernetesLabels() prometheus.Labels {
+	return KubernetesLabelsFrom(defaultPodInfoDir)
+}
+
+func KubernetesLabelsFrom(podInfoDir string) prometheus.Labels {
+	labels := prometheus.Labels{}
+	setLabel(labels, "pod", firstNonEmpty(
+		os.Getenv("POD_NAME"),
+		readPodInfo(filepath.Join(podInfoDir, "name")),
+		os.Getenv("HOSTNAME"),
+	))
+	setLabel(labels, "pod_namespace", firstNonEmpty(
+		os.Getenv("POD_NAMESPACE"),
+		readPodInfo(filepath.Join(podInfoDir, "namespace")),
+		readPodInfo(serviceAccountNamespace),
+	))
+	setLabel(labels, "node", firstNonEmpty(
+		os.Getenv("NODE_NAME"),
+		readPodInfo(filepath.Join(podInfoDir, "nodename")),
+	))
+	return labels
+}
+
+func readPodInfo(filename string) string {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func InfoLabels(labels prometheus.Labels) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		if prom.InfoLabels == nil {
+			prom.InfoLabels = prometheus.Labels{}
+		}
+		for name, value := range labels {
+			prom.InfoLabels[name] = value
+		}
+	}
+}
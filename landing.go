@@ -0,0 +1,73 @@
+package muxprom
+
+import (
+	"html/template"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+type LandingLink struct {
+	Name string
+	Path string
+}
+
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{range .Links}}<li><a href="{{.Path}}">{{.Name}}</a></li>
+{{end}}</ul>
+<h2>Build info</h2>
+<table>
+<tr><td>Go version</td><td>{{.GoVersion}}</td></tr>
+{{if .Path}}<tr><td>Path</td><td>{{.Path}}</td></tr>
+<tr><td>Version</td><td>{{.Version}}</td></tr>
+{{end}}{{range .VCS}}<tr><td>{{.Key}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (prom *MuxProm) landingHandler(w http.ResponseWriter, r *http.Request) {
+	links := []LandingLink{{Name: "Metrics", Path: prom.MetricsPath}}
+	if prom.TogglePath != "" {
+		links = append(links, LandingLink{Name: "Instrumentation toggle", Path: prom.TogglePath})
+	}
+	if prom.StatsPath != "" {
+		links = append(links, LandingLink{Name: "Stats", Path: prom.StatsPath})
+	}
+	prom.mu.RLock()
+	links = append(links, prom.LandingLinks...)
+	prom.mu.RUnlock()
+
+	data := struct {
+		Title     string
+		Links     []LandingLink
+		GoVersion string
+		Path      string
+		Version   string
+		VCS       []debug.BuildSetting
+	}{
+		Title:     prom.Namespace,
+		Links:     links,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		data.Path = bi.Main.Path
+		data.Version = bi.Main.Version
+		for _, setting := range bi.Settings {
+			if strings.HasPrefix(setting.Key, "vcs.") {
+				data.VCS = append(data.VCS, setting)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := landingTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
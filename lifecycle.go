@@ -0,0 +1,168 @@
+package muxprom
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+var ErrAlreadyInstrumented = errors.New("muxprom: router is already instrumented")
+
+// routers serializes all modifications of mux.Routers, which are not safe for
+// concurrent use, and tracks which MuxProm instruments which router.
+var routers = struct {
+	sync.Mutex
+	owners map[*mux.Router]*MuxProm
+}{owners: make(map[*mux.Router]*MuxProm)}
+
+func claimRouter(r *mux.Router, prom *MuxProm) error {
+	if owner, ok := routers.owners[r]; ok {
+		if owner == prom {
+			return ErrAlreadyInstrumented
+		}
+		return fmt.Errorf("%w by another MuxProm (namespace %q)", ErrAlreadyInstrumented, owner.Namespace)
+	}
+	routers.owners[r] = prom
+	return nil
+}
+
+func releaseRouter(r *mux.Router, prom *MuxProm) {
+	routers.Lock()
+	defer routers.Unlock()
+	if routers.owners[r] == prom {
+		delete(routers.owners, r)
+	}
+}
+
+func (prom *MuxProm) Close() error {
+	if !atomic.CompareAndSwapInt32(&prom.closed, 0, 1) {
+		return nil
+	}
+	if prom.Router != nil {
+		releaseRouter(prom.Router, prom)
+	}
+	for _, sub := range prom.subrouters {
+		releaseRouter(sub, prom)
+	}
+	for _, m := range prom.mounts {
+		m.release(prom)
+	}
+	if prom.tuner != nil {
+		prom.tuner.stop()
+	}
+	var errs []error
+	if prom.StateFile != "" {
+		// A failed save must not keep the collectors registered.
+		if err := prom.saveState(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if prom.Registerer != nil && prom.registered == prom && !prom.wrapRegisterer(prom.Registerer).Unregister(prom) {
+		errs = append(errs, errors.New("muxprom: collectors were not registered"))
+	}
+	return errors.Join(errs...)
+}
+
+func (prom *MuxProm) isClosed() bool {
+	return atomic.LoadInt32(&prom.closed) == 1
+}
+
+// mount serves one of the routes muxprom adds (metrics, toggle, ...).
+// Neither mux.Router nor http.ServeMux can remove a route, so after Close the
+// route stops matching and the next MuxProm mounting the same route on the
+// same router takes it over instead of adding another one.
+type mount struct {
+	mu      sync.RWMutex
+	owner   *MuxProm
+	handler http.Handler
+}
+
+func (m *mount) serving() http.Handler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.owner == nil || m.owner.isClosed() {
+		return nil
+	}
+	return m.handler
+}
+
+func (m *mount) match(*http.Request, *mux.RouteMatch) bool {
+	return m.serving() != nil
+}
+
+func (m *mount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := m.serving()
+	if h == nil {
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (m *mount) claim(prom *MuxProm, h http.Handler) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.owner != nil && !m.owner.isClosed() {
+		return false
+	}
+	m.owner, m.handler = prom, h
+	return true
+}
+
+func (m *mount) release(prom *MuxProm) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.owner == prom {
+		m.owner, m.handler = nil, nil
+	}
+}
+
+func (m *mount) ownedBy(prom *MuxProm) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.owner == prom && !prom.isClosed()
+}
+
+// mountRoute adds a route to the Router or ServeMux, or takes over the one a
+// closed MuxProm left behind. The caller holds the routers lock.
+func (prom *MuxProm) mountRoute(name string, path string, h http.Handler, methods ...string) *mount {
+	var m *mount
+	if prom.Router != nil {
+		prom.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			left, ok := route.GetHandler().(*mount)
+			if tpl, _ := route.GetPathTemplate(); ok && m == nil && route.GetName() == name && tpl == path && left.claim(prom, h) {
+				m = left
+			}
+			return nil
+		})
+		if m == nil {
+			m = &mount{owner: prom, handler: h}
+			prom.Router.Name(name).Methods(methods...).Path(path).MatcherFunc(m.match).Handler(m)
+		}
+	} else {
+		// A pattern ending in a slash would match every path below it, so
+		// e.g. a landing page on "/" would answer for all unknown paths.
+		pattern := path
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "{$}"
+		}
+		probe := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}}
+		if handler, registered := prom.ServeMux.Handler(probe); registered == pattern {
+			if left, ok := handler.(*mount); ok && left.claim(prom, h) {
+				m = left
+			}
+		}
+		if m == nil {
+			m = &mount{owner: prom, handler: h}
+			prom.ServeMux.Handle(pattern, m)
+		}
+	}
+	prom.mounts = append(prom.mounts, m)
+	return m
+}
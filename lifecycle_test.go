@@ -0,0 +1,193 @@
+package muxprom
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}).Name("hello")
+	return r
+}
+
+func TestConcurrentNewInstrumentClose(t *testing.T) {
+	router := newTestRouter()
+	reg := prometheus.NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prom, err := New(Router(router), Registry(reg))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := prom.Instrument(); err != nil && !errors.Is(err, ErrAlreadyInstrumented) {
+				t.Error(err)
+			}
+			if err := prom.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentServeAndClose(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		router := newTestRouter()
+		prom, err := New(Router(router), Registry(reg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := prom.Instrument(); err != nil {
+			t.Fatal(err)
+		}
+
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 50; k++ {
+					for _, path := range []string{"/hello", "/metrics", "/missing"} {
+						router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := prom.Close(); err != nil {
+				t.Error(err)
+			}
+			if err := prom.Instrument(); err == nil {
+				t.Error("Instrument succeeded after Close")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseReusesRoutes(t *testing.T) {
+	router := newTestRouter()
+	countRoutes := func() int {
+		n := 0
+		router.Walk(func(*mux.Route, *mux.Router, []*mux.Route) error {
+			n++
+			return nil
+		})
+		return n
+	}
+
+	var want int
+	for i := 0; i < 3; i++ {
+		prom, err := New(Router(router), Registry(prometheus.NewRegistry()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			want = countRoutes()
+		} else if got := countRoutes(); got != want {
+			t.Fatalf("cycle %d: %d routes, want %d", i, got, want)
+		}
+		if err := prom.Healthy(); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("cycle %d: /metrics returned %d", i, rec.Code)
+		}
+		if err := prom.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := prom.Healthy(); err == nil {
+			t.Fatal("Healthy succeeded after Close")
+		}
+	}
+
+	sm := http.NewServeMux()
+	for i := 0; i < 2; i++ {
+		prom, err := New(ServeMux(sm), Registry(prometheus.NewRegistry()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := prom.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCloseUnregistersWhenSavingStateFails(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	stateFile := t.TempDir() + "/missing/state.prom"
+	prom, err := New(Router(newTestRouter()), Registry(reg), StateFile(stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prom.Close(); err == nil {
+		t.Fatal("Close succeeded although the state file can't be written")
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		t.Errorf("%s still registered after Close", mf.GetName())
+	}
+}
+
+func TestServeMuxLandingPageExactMatch(t *testing.T) {
+	sm := http.NewServeMux()
+	sm.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	for i := 0; i < 2; i++ {
+		prom, err := New(ServeMux(sm), Registry(prometheus.NewRegistry()), LandingPage("/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			path string
+			want int
+		}{
+			{path: "/", want: http.StatusOK},
+			{path: "/hello", want: http.StatusOK},
+			{path: "/missing", want: http.StatusNotFound},
+			{path: "/metrics", want: http.StatusOK},
+		}
+		for _, tt := range tests {
+			rec := httptest.NewRecorder()
+			prom.Middleware(sm).ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("cycle %d: %s returned %d, want %d", i, tt.path, rec.Code, tt.want)
+			}
+		}
+		if err := prom.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package muxprom
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+type printlnLogger struct {
+	logger Logger
+	msg    string
+}
+
+func (l printlnLogger) Println(v ...interface{}) {
+	l.logger.Error(l.msg, "error", fmt.Sprint(v...))
+}
+
+func defaultLogger() Logger {
+	return slog.Default()
+}
@@ -0,0 +1,64 @@
+package muxprom
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var longPollBucket = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+type longPolls struct {
+	routes   map[string]bool
+	duration *prometheus.HistogramVec
+	active   *prometheus.GaugeVec
+}
+
+func LongPollRoutes(routes ...string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.LongPollRoutes = append(prom.LongPollRoutes, routes...)
+	}
+}
+
+func newLongPolls(prom *MuxProm) *longPolls {
+	l := &longPolls{
+		routes: make(map[string]bool, len(prom.LongPollRoutes)),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_long_poll_duration_seconds",
+				Help:      "HTTP long-poll request duration seconds, kept out of http_request_duration_seconds",
+				Buckets:   longPollBucket,
+			},
+			[]string{"route", "method", "http_status"},
+		),
+		active: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_long_polls_active",
+				Help:      "HTTP long-poll requests currently waiting by route",
+			},
+			[]string{"route"},
+		),
+	}
+	for _, route := range prom.LongPollRoutes {
+		l.routes[route] = true
+	}
+	return l
+}
+
+func (l *longPolls) start(state *requestState) {
+	state.longPoll = true
+	state.longPollRoute = state.route
+	l.active.WithLabelValues(state.route).Inc()
+}
+
+func (l *longPolls) end(state *requestState) {
+	l.active.WithLabelValues(state.longPollRoute).Dec()
+}
+
+func MarkLongPoll(ctx context.Context) {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok && !state.longPoll {
+		state.longPolls.start(state)
+	}
+}
@@ -0,0 +1,27 @@
+package muxprom
+
+import (
+	"strings"
+)
+
+const aggregatedRoute = "aggregated"
+
+func SkipMethods(methods ...string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.SkipMethods = append(prom.SkipMethods, methods...)
+	}
+}
+
+func AggregateMethods(methods ...string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.AggregateMethods = append(prom.AggregateMethods, methods...)
+	}
+}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[strings.ToUpper(m)] = true
+	}
+	return set
+}
@@ -0,0 +1,100 @@
+package muxprom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type MigrationReport struct {
+	MetricNames map[string]string
+	RouteLabels []RouteLabelChange
+	Notes       []string
+}
+
+type RouteLabelChange struct {
+	Old string
+	New string
+}
+
+func (r MigrationReport) String() string {
+	var b strings.Builder
+	writeMapping := func(title string, m map[string]string) {
+		if len(m) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n", title)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "  %s -> %s\n", k, m[k])
+		}
+	}
+	writeMapping("metric names", r.MetricNames)
+	if len(r.RouteLabels) > 0 {
+		fmt.Fprintf(&b, "route labels:\n")
+		for _, c := range r.RouteLabels {
+			fmt.Fprintf(&b, "  %q -> %q\n", c.Old, c.New)
+		}
+	}
+	for _, n := range r.Notes {
+		fmt.Fprintf(&b, "note: %s\n", n)
+	}
+	return b.String()
+}
+
+func Migrate(options ...func(*MuxProm)) ([]func(*MuxProm), MigrationReport) {
+	p := defaults()
+	for _, option := range options {
+		option(p)
+	}
+	report := MigrationReport{MetricNames: make(map[string]string)}
+	if p.SchemaVersion >= SchemaV2 {
+		report.Notes = append(report.Notes, "already using the current schema, nothing to migrate")
+		return options, report
+	}
+
+	migrated := append(append([]func(*MuxProm){}, options...), SchemaVersion(SchemaV2), LegacyMetricNames(true))
+	for _, name := range []string{"http_response_size", "http_client_response_size"} {
+		report.MetricNames[p.Namespace+"_"+name] = p.Namespace + "_" + sizeMetricName(SchemaV2, name)
+	}
+	report.Notes = append(report.Notes, "LegacyMetricNames keeps emitting the old metric names; remove it once dashboards and alerts are migrated")
+
+	switch {
+	case p.RouteLabeler != nil:
+		report.Notes = append(report.Notes, "a custom RouteLabeler is set, route labels are unchanged")
+	case p.Router != nil:
+		routers.Lock()
+		p.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			name := route.GetName()
+			if route.GetHandler() == nil || p.isOwnRouteName(name) {
+				return nil
+			}
+			next := name
+			if tpl, err := route.GetPathTemplate(); err == nil && tpl != "" {
+				next = tpl
+			}
+			change := RouteLabelChange{Old: p.normalizeRouteLabel(name), New: p.normalizeRouteLabel(next)}
+			if change.Old != change.New {
+				report.RouteLabels = append(report.RouteLabels, change)
+			}
+			return nil
+		})
+		routers.Unlock()
+		sort.Slice(report.RouteLabels, func(i, j int) bool {
+			a, b := report.RouteLabels[i], report.RouteLabels[j]
+			return a.Old < b.Old || a.Old == b.Old && a.New < b.New
+		})
+		if len(report.RouteLabels) > 0 {
+			report.Notes = append(report.Notes, "route labels change from route names to path templates; queries, alerts and dashboards selecting the old labels need updating")
+		}
+	case p.ServeMux == nil:
+		report.Notes = append(report.Notes, "route labels change from gorilla/mux route names to path templates")
+	}
+	return migrated, report
+}
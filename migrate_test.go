@@ -0,0 +1,50 @@
+package muxprom
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMigrateRouteLabels(t *testing.T) {
+	router := mux.NewRouter()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Name("Users").Path("/users/{id}").Handler(handler)
+	router.Name("/about").Path("/about").Handler(handler)
+	router.Path("/health").Handler(handler)
+	router.Name("Orders").Path("/orders/")
+
+	tests := []struct {
+		name    string
+		options []func(*MuxProm)
+		want    []RouteLabelChange
+	}{
+		{
+			name: "names to templates",
+			want: []RouteLabelChange{{Old: "", New: "/health"}, {Old: "Users", New: "/users/{id}"}},
+		},
+		{
+			name:    "normalized labels",
+			options: []func(*MuxProm){LowercaseRouteLabels(true)},
+			want:    []RouteLabelChange{{Old: "", New: "/health"}, {Old: "users", New: "/users/{id}"}},
+		},
+		{
+			name:    "custom labeler",
+			options: []func(*MuxProm){RouteLabeler(MuxRouteLabeler)},
+		},
+		{
+			name:    "current schema",
+			options: []func(*MuxProm){SchemaVersion(SchemaV2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, report := Migrate(append([]func(*MuxProm){Router(router)}, tt.options...)...)
+			if !reflect.DeepEqual(report.RouteLabels, tt.want) {
+				t.Errorf("route labels %v, want %v", report.RouteLabels, tt.want)
+			}
+		})
+	}
+}
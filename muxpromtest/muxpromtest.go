@@ -0,0 +1,126 @@
+package muxpromtest
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rusart/muxprom"
+)
+
+func gatherer(prom *muxprom.MuxProm) prometheus.Gatherer {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(prom)
+	return reg
+}
+
+func SnapshotMetrics(prom *muxprom.MuxProm) (string, error) {
+	mfs, err := gatherer(prom).Gather()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func ExpectMetrics(t testing.TB, prom *muxprom.MuxProm, expected string, metricNames ...string) {
+	t.Helper()
+	if err := testutil.GatherAndCompare(gatherer(prom), strings.NewReader(expected), metricNames...); err != nil {
+		t.Error(err)
+	}
+}
+
+func Count(prom *muxprom.MuxProm, route string, method string, status int) (uint64, error) {
+	mfs, err := gatherer(prom).Gather()
+	if err != nil {
+		return 0, err
+	}
+	name := prom.Namespace + "_http_request_duration_seconds"
+	want := map[string]string{"route": route, "method": method, "http_status": strconv.Itoa(status)}
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			matched := 0
+			for _, lp := range m.GetLabel() {
+				if v, ok := want[lp.GetName()]; ok && v == lp.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(want) {
+				return m.GetHistogram().GetSampleCount(), nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+func ExpectCount(t testing.TB, prom *muxprom.MuxProm, route string, method string, status int, n uint64) {
+	t.Helper()
+	got, err := Count(prom, route, method, status)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got != n {
+		t.Errorf("muxpromtest: %s %s %d: got %d requests, want %d", method, route, status, got, n)
+	}
+}
+
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func NewServer(t testing.TB, router *mux.Router, options ...func(*muxprom.MuxProm)) (*httptest.Server, *muxprom.MuxProm) {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	options = append([]func(*muxprom.MuxProm){muxprom.Registry(reg)}, options...)
+	prom, err := muxprom.New(append(options, muxprom.Router(router))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prom.Instrument(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(router)
+	t.Cleanup(func() {
+		srv.Close()
+		prom.Close()
+	})
+	return srv, prom
+}
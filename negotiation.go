@@ -0,0 +1,80 @@
+package muxprom
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type contentNegotiation struct {
+	requests *prometheus.CounterVec
+}
+
+func ContentNegotiation(enabled bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ContentNegotiation = enabled
+	}
+}
+
+func newContentNegotiation(prom *MuxProm) *contentNegotiation {
+	return &contentNegotiation{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_requests_by_accept_total",
+				Help:      "HTTP requests by preferred representation in the Accept header and whether the response matched it",
+			},
+			[]string{"route", "accept", "matched"},
+		),
+	}
+}
+
+func (c *contentNegotiation) observe(r *http.Request, route string, contentType string) {
+	accept := preferredRepresentation(r.Header.Get("Accept"))
+	matched := accept == "any" || accept == representationClass(contentType)
+	c.requests.WithLabelValues(route, accept, strconv.FormatBool(matched)).Inc()
+}
+
+// preferredRepresentation classifies the media range with the highest
+// quality; ties go to the first one listed.
+func preferredRepresentation(accept string) string {
+	best, bestQ := "", -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > bestQ && q > 0 {
+			best, bestQ = mediaType, q
+		}
+	}
+	if best == "" || best == "*/*" {
+		return "any"
+	}
+	return representationClass(best)
+}
+
+func representationClass(mediaType string) string {
+	if i := strings.IndexByte(mediaType, ';'); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return "json"
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return "xml"
+	case mediaType == "text/html":
+		return "html"
+	}
+	return "other"
+}
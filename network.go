@@ -0,0 +1,90 @@
+package muxprom
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const externalNetwork = "external"
+
+type NetworkClass struct {
+	Name  string
+	CIDRs []string
+}
+
+type networkClassifier struct {
+	classes  []parsedNetworkClass
+	requests *prometheus.CounterVec
+}
+
+type parsedNetworkClass struct {
+	name string
+	nets []*net.IPNet
+}
+
+func NetworkClasses(classes ...NetworkClass) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.NetworkClasses = append(prom.NetworkClasses, classes...)
+	}
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func newNetworkClassifier(prom *MuxProm) (*networkClassifier, error) {
+	c := &networkClassifier{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_requests_by_network_total",
+				Help:      "HTTP requests by client network class",
+			},
+			[]string{"route", "method", "http_status", "network"},
+		),
+	}
+	for _, class := range prom.NetworkClasses {
+		if class.Name == "" || class.Name == externalNetwork {
+			return nil, fmt.Errorf("muxprom: invalid network class name %q", class.Name)
+		}
+		nets, err := parseCIDRs(class.CIDRs)
+		if err != nil {
+			return nil, fmt.Errorf("muxprom: network class %s: %w", class.Name, err)
+		}
+		c.classes = append(c.classes, parsedNetworkClass{name: class.Name, nets: nets})
+	}
+	return c, nil
+}
+
+func (c *networkClassifier) classify(ip net.IP) string {
+	if ip == nil {
+		return externalNetwork
+	}
+	for _, class := range c.classes {
+		for _, n := range class.nets {
+			if n.Contains(ip) {
+				return class.name
+			}
+		}
+	}
+	return externalNetwork
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,38 @@
+package muxprom
+
+import (
+	"context"
+	"net/http"
+)
+
+type NoopMuxProm struct{}
+
+func Noop() *NoopMuxProm {
+	return &NoopMuxProm{}
+}
+
+func (NoopMuxProm) Instrument() error {
+	return nil
+}
+
+func (NoopMuxProm) Middleware(next http.Handler) http.Handler {
+	return next
+}
+
+func (NoopMuxProm) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	})
+}
+
+func (NoopMuxProm) Healthy() error {
+	return nil
+}
+
+func (NoopMuxProm) Drain(ctx context.Context) error {
+	return nil
+}
+
+func (NoopMuxProm) Close() error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package muxprom
+
+import (
+	"strings"
+)
+
+func StripTrailingSlash(strip bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.StripTrailingSlash = strip
+	}
+}
+
+func LowercaseRouteLabels(lower bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.LowercaseRouteLabels = lower
+	}
+}
+
+func (prom *MuxProm) normalizeRouteLabel(route string) string {
+	if prom.StripTrailingSlash && len(route) > 1 {
+		if trimmed := strings.TrimRight(route, "/"); trimmed != "" {
+			route = trimmed
+		} else {
+			route = "/"
+		}
+	}
+	if prom.LowercaseRouteLabels {
+		route = strings.ToLower(route)
+	}
+	return route
+}
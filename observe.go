@@ -0,0 +1,38 @@
+package muxprom
+
+import (
+	"io"
+	"time"
+)
+
+type RouteInfo struct {
+	Route        string
+	Method       string
+	RequestID    string
+	Status       int
+	Duration     time.Duration
+	RequestSize  int64
+	ResponseSize int
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	length int64
+
+	// Read times are only taken with a clock, for upload routes.
+	clock     Clock
+	firstRead time.Time
+	lastRead  time.Time
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	if r.clock != nil && r.firstRead.IsZero() {
+		r.firstRead = r.clock.Now()
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.length += int64(n)
+	if r.clock != nil {
+		r.lastRead = r.clock.Now()
+	}
+	return n, err
+}
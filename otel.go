@@ -0,0 +1,82 @@
+package muxprom
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var otelInstrumentationName = "github.com/rusart/muxprom"
+
+type otelInstruments struct {
+	activeRequests  metric.Int64UpDownCounter
+	requestDuration metric.Float64Histogram
+	responseSize    metric.Int64Histogram
+}
+
+func newOtelInstruments(mp metric.MeterProvider, durationBucket []float64, respSizeBucket []float64) (*otelInstruments, error) {
+	meter := mp.Meter(otelInstrumentationName)
+	activeRequests, err := meter.Int64UpDownCounter(
+		"http.server.active_requests",
+		metric.WithDescription("Number of active HTTP server requests."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	requestDuration, err := meter.Float64Histogram(
+		"http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBucket...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	responseSize, err := meter.Int64Histogram(
+		"http.server.response.body.size",
+		metric.WithDescription("Size of HTTP server response bodies."),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(respSizeBucket...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &otelInstruments{
+		activeRequests:  activeRequests,
+		requestDuration: requestDuration,
+		responseSize:    responseSize,
+	}, nil
+}
+
+func (o *otelInstruments) IncInflight(route string, method string) {
+	o.activeRequests.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("http.route", route),
+		attribute.String("http.request.method", method),
+	))
+}
+
+func (o *otelInstruments) DecInflight(route string, method string) {
+	o.activeRequests.Add(context.Background(), -1, metric.WithAttributes(
+		attribute.String("http.route", route),
+		attribute.String("http.request.method", method),
+	))
+}
+
+func (o *otelInstruments) ObserveDuration(ctx context.Context, route string, method string, status int, d time.Duration) {
+	o.requestDuration.Record(ctx, d.Seconds(), metric.WithAttributes(
+		attribute.String("http.route", route),
+		attribute.String("http.request.method", method),
+		attribute.Int("http.response.status_code", status),
+	))
+}
+
+func (o *otelInstruments) ObserveSize(ctx context.Context, route string, method string, status int, bytes int) {
+	o.responseSize.Record(ctx, int64(bytes), metric.WithAttributes(
+		attribute.String("http.route", route),
+		attribute.String("http.request.method", method),
+		attribute.Int("http.response.status_code", status),
+	))
+}
@@ -0,0 +1,29 @@
+package muxprom
+
+import (
+	"time"
+)
+
+func startPeriodic(logger Logger, interval time.Duration, what string, tick func() error, final func() error) (stop func() error) {
+	done := make(chan struct{})
+	finished := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := tick(); err != nil {
+					logger.Error("muxprom: "+what+" failed", "error", err)
+				}
+			case <-done:
+				finished <- final()
+				return
+			}
+		}
+	}()
+	return func() error {
+		close(done)
+		return <-finished
+	}
+}
@@ -0,0 +1,224 @@
+package muxprom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// restoredState holds counter and histogram values saved by a previous
+// process, keyed by metric family name and label set.
+type restoredState struct {
+	families map[string]*dto.MetricFamily
+	metrics  map[string]map[string]*dto.Metric
+}
+
+func loadState(filename string) (*restoredState, float64, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("muxprom: reading state file failed: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("muxprom: reading state file failed: %w", err)
+	}
+	state, err := parseState(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("muxprom: parsing state file %s failed: %w", filename, err)
+	}
+	return state, float64(info.ModTime().UnixNano()) / 1e9, nil
+}
+
+func newRestoredState() *restoredState {
+	return &restoredState{
+		families: make(map[string]*dto.MetricFamily),
+		metrics:  make(map[string]map[string]*dto.Metric),
+	}
+}
+
+func parseState(r io.Reader) (*restoredState, error) {
+	var parser expfmt.TextParser
+	mfs, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+	state := newRestoredState()
+	for _, mf := range mfs {
+		if !persistable(mf) {
+			continue
+		}
+		for _, m := range mf.Metric {
+			if h := m.Histogram; h != nil && len(h.Bucket) > 0 && math.IsInf(h.Bucket[len(h.Bucket)-1].GetUpperBound(), 1) {
+				// The text format spells out the +Inf bucket, the client keeps it implicit.
+				h.Bucket = h.Bucket[:len(h.Bucket)-1]
+			}
+		}
+		state.add(mf)
+	}
+	return state, nil
+}
+
+// add merges the values of mf into the state.
+func (s *restoredState) add(mf *dto.MetricFamily) {
+	name := mf.GetName()
+	family, ok := s.families[name]
+	if !ok {
+		family = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}
+		s.families[name] = family
+		s.metrics[name] = make(map[string]*dto.Metric)
+	}
+	for _, m := range mf.Metric {
+		key := labelKey(m.Label)
+		if existing, ok := s.metrics[name][key]; ok {
+			addMetricValues(existing, m)
+			continue
+		}
+		family.Metric = append(family.Metric, m)
+		s.metrics[name][key] = m
+	}
+}
+
+func persistable(mf *dto.MetricFamily) bool {
+	return mf.GetType() == dto.MetricType_COUNTER || mf.GetType() == dto.MetricType_HISTOGRAM
+}
+
+func labelKey(labels []*dto.LabelPair) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.GetName())
+		b.WriteByte(0)
+		b.WriteString(l.GetValue())
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+type restoredGatherer struct {
+	gatherer prometheus.Gatherer
+	state    *restoredState
+}
+
+func (g restoredGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	seen := make(map[string]bool)
+	for _, mf := range mfs {
+		base, ok := g.state.metrics[mf.GetName()]
+		if !ok || mf.GetType() != g.state.families[mf.GetName()].GetType() {
+			continue
+		}
+		seen[mf.GetName()] = true
+		present := make(map[string]bool, len(mf.Metric))
+		for _, m := range mf.Metric {
+			key := labelKey(m.Label)
+			present[key] = true
+			if b, ok := base[key]; ok {
+				addMetricValues(m, b)
+			}
+		}
+		for key, b := range base {
+			if !present[key] {
+				mf.Metric = append(mf.Metric, b)
+			}
+		}
+		sort.Slice(mf.Metric, func(i, j int) bool {
+			return labelKey(mf.Metric[i].Label) < labelKey(mf.Metric[j].Label)
+		})
+	}
+	added := false
+	for name, mf := range g.state.families {
+		if !seen[name] {
+			mfs = append(mfs, mf)
+			added = true
+		}
+	}
+	if added {
+		sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+	}
+	return mfs, err
+}
+
+func addMetricValues(m *dto.Metric, base *dto.Metric) {
+	if m.Counter != nil && base.Counter != nil {
+		v := m.Counter.GetValue() + base.Counter.GetValue()
+		m.Counter.Value = &v
+		return
+	}
+	// Bucket layouts that changed between restarts cannot be merged.
+	if m.Histogram == nil || base.Histogram == nil || !sameBuckets(m.Histogram, base.Histogram) {
+		return
+	}
+	for i, b := range m.Histogram.Bucket {
+		c := b.GetCumulativeCount() + base.Histogram.Bucket[i].GetCumulativeCount()
+		b.CumulativeCount = &c
+	}
+	count := m.Histogram.GetSampleCount() + base.Histogram.GetSampleCount()
+	sum := m.Histogram.GetSampleSum() + base.Histogram.GetSampleSum()
+	m.Histogram.SampleCount = &count
+	m.Histogram.SampleSum = &sum
+}
+
+func (prom *MuxProm) saveState() error {
+	reg := prometheus.NewRegistry()
+	if err := prom.wrapRegisterer(reg).Register(prom.registered); err != nil {
+		return fmt.Errorf("muxprom: saving state failed: %w", err)
+	}
+	var g prometheus.Gatherer = reg
+	if prom.restored != nil {
+		g = restoredGatherer{gatherer: reg, state: prom.restored}
+	}
+	mfs, err := g.Gather()
+	if err != nil {
+		return fmt.Errorf("muxprom: saving state failed: %w", err)
+	}
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if !persistable(mf) {
+			continue
+		}
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			return fmt.Errorf("muxprom: saving state failed: %w", err)
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(prom.StateFile), filepath.Base(prom.StateFile)+".*")
+	if err != nil {
+		return fmt.Errorf("muxprom: saving state failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("muxprom: saving state failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("muxprom: saving state failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), prom.StateFile); err != nil {
+		return fmt.Errorf("muxprom: saving state failed: %w", err)
+	}
+	return nil
+}
+
+func sameBuckets(a *dto.Histogram, b *dto.Histogram) bool {
+	if len(a.Bucket) != len(b.Bucket) {
+		return false
+	}
+	for i, bucket := range a.Bucket {
+		if bucket.GetUpperBound() != b.Bucket[i].GetUpperBound() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package muxprom
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// requestCount sums the request duration histogram counts of a scrape.
+func requestCount(t *testing.T, r io.Reader) uint64 {
+	t.Helper()
+	var parser expfmt.TextParser
+	mfs, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n uint64
+	if mf, ok := mfs["muxprom_http_request_duration_seconds"]; ok {
+		for _, m := range mf.Metric {
+			n += m.GetHistogram().GetSampleCount()
+		}
+	}
+	return n
+}
+
+// serveRun starts an instrumented router on a fresh registry, serves
+// requests to it and returns its MuxProm.
+func serveRun(t *testing.T, requests int, options ...func(*MuxProm)) *MuxProm {
+	t.Helper()
+	router := newTestRouter()
+	prom, err := New(append([]func(*MuxProm){Router(router), Registry(prometheus.NewRegistry())}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prom.Instrument(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < requests; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+	}
+	return prom
+}
+
+func TestStateFile(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []int
+	}{
+		{name: "single run", runs: []int{2}},
+		{name: "restart", runs: []int{2, 3}},
+		{name: "restart without requests", runs: []int{2, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFile := filepath.Join(t.TempDir(), "state.prom")
+			var total uint64
+			for i, requests := range tt.runs {
+				prom := serveRun(t, requests, StateFile(stateFile))
+				total += uint64(requests)
+
+				rec := httptest.NewRecorder()
+				prom.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("run %d: scrape status %d", i, rec.Code)
+				}
+				if got := requestCount(t, rec.Body); got != total {
+					t.Errorf("run %d: scraped %d requests, want %d", i, got, total)
+				}
+				if err := prom.Close(); err != nil {
+					t.Fatalf("run %d: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStateFileCorrupt(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.prom")
+	if err := os.WriteFile(stateFile, []byte("not { a metric"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Registry(prometheus.NewRegistry()), StateFile(stateFile)); err == nil {
+		t.Error("New succeeded with a corrupt state file")
+	}
+}
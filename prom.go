@@ -2,28 +2,42 @@ package muxprom
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/bytefmt"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const StatusDeadlineExceeded = 499
+
 var defaultMetricsPath = "/metrics"
 var defaultMetricsRouteName = "metrics"
+var defaultToggleRouteName = "muxprom-toggle"
+var defaultLandingRouteName = "muxprom-landing"
 var defaultNamespace = "muxprom"
 var defaultDurationBucket = []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
 var defaultRespSizeBucket = []float64{0, 512, bytefmt.KILOBYTE, 100 * bytefmt.KILOBYTE, 512 * bytefmt.KILOBYTE, bytefmt.MEGABYTE, 5 * bytefmt.MEGABYTE, 10 * bytefmt.MEGABYTE, 25 * bytefmt.MEGABYTE, 50 * bytefmt.MEGABYTE, 100 * bytefmt.MEGABYTE, 500 * bytefmt.MEGABYTE}
 
 type statusWriter struct {
 	http.ResponseWriter
-	status int
-	length int
+	status     int
+	length     int
+	timeWrites bool
+	writeTime  time.Duration
+	clock      Clock
+	hijacked   bool
 }
 
 func (w *statusWriter) WriteHeader(status int) {
@@ -35,16 +49,36 @@ func (w *statusWriter) Write(b []byte) (int, error) {
 	if w.status == 0 {
 		w.status = 200
 	}
+	if w.timeWrites {
+		defer w.addWriteTime(w.clock.Now())
+	}
 	n, err := w.ResponseWriter.Write(b)
 	w.length += n
 	return n, err
 }
 
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = 200
+	}
+	if w.timeWrites {
+		defer w.addWriteTime(w.clock.Now())
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) addWriteTime(start time.Time) {
+	w.writeTime += w.clock.Since(start)
+}
+
 func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	writer, ok := w.ResponseWriter.(http.Hijacker)
 	if !ok {
 		return nil, nil, fmt.Errorf("not supported by the underlying writer")
 	}
+	w.hijacked = true
 	return writer.Hijack()
 }
 
@@ -52,14 +86,136 @@ type MuxProm struct {
 	reqInFlight          prometheus.GaugeVec
 	reqDurationHistogram prometheus.HistogramVec
 	reqRespSizeHistogram prometheus.HistogramVec
+	recorder             Recorder
+	tracer               trace.Tracer
+	reqPhaseHistogram    *prometheus.HistogramVec
+	reqStageHistogram    *prometheus.HistogramVec
+	reqRespSizeLegacy    *prometheus.HistogramVec
+	routeDurations       map[string]*prometheus.HistogramVec
+	graphql              *graphqlOperations
+	hits                 routeHits
+	cardinality          routeCardinality
+	errorClasses         *errorClasses
+	agentClasses         *agentClasses
+	networks             *networkClassifier
+	trustedProxies       []*net.IPNet
+	events               *eventStream
+	tenants              *tenantRegistries
+	rateLimiters         *rateLimiters
+	shedder              *loadShedder
+	policyLabeler        func(*http.Request) string
+	concurrency          *concurrencyLimiters
+	cache                *responseCache
+	compression          *compressionMetrics
+	bodyRejected         *prometheus.CounterVec
+	cors                 *corsHandler
+	tooManyRequests      *tooManyRequests
+	conditional          *conditionalRequests
+	longPolls            *longPolls
+	uploads              *uploads
+	negotiation          *contentNegotiation
+	stats                *slidingStats
+	subrouters           []*mux.Router
+	mounts               []*mount
+	metricsMount         *mount
+	skipMethods          map[string]bool
+	aggregateMethods     map[string]bool
+	tuner                *bucketTuner
+	slos                 *sloTracker
+	restored             *restoredState
+	breakers             *breakerMetrics
+	unaccounted          *prometheus.CounterVec
+	draining             prometheus.Gauge
+	emptyResponses       *prometheus.CounterVec
+	deadlineExceeded     *prometheus.CounterVec
+	inflight             atomic.Int64
+	collectors           []prometheus.Collector
+	registered           *MuxProm
+	disabled             int32
+	closed               int32
+	exemplarCount        atomic.Uint64
+	mu                   sync.RWMutex
 
 	Router           *mux.Router
+	ServeMux         *http.ServeMux
+	RouteLabeler     func(*http.Request) string
 	Namespace        string
 	MetricsPath      string
 	MetricsRouteName string
+	TogglePath       string
+	ToggleRouteName  string
+	LandingPath      string
+	LandingRouteName string
+	LandingLinks     []LandingLink
+	StatsPath        string
+	StatsRouteName   string
+
+	StripTrailingSlash   bool
+	LowercaseRouteLabels bool
+
+	DurationBucket      []float64
+	BucketsByRoute      map[string][]float64
+	BucketTuningWindow  time.Duration
+	RespSizeBucket      []float64
+	ScrapeCacheTTL      time.Duration
+	Exemplars           bool
+	ExemplarMinDuration time.Duration
+	ExemplarSampleRate  int
+
+	PushDeleteOnStop       bool
+	SchemaVersion          int
+	SLOs                   []SLO
+	StateFile              string
+	LegacyMetricNames      bool
+	ExcludeNotModifiedSize bool
 
-	DurationBucket []float64
-	RespSizeBucket []float64
+	RouteCardinalityThreshold int
+	ClassifyError             func(*http.Request, int) string
+	ClassifyUserAgent         func(*http.Request) string
+	ContentNegotiation        bool
+	NetworkClasses            []NetworkClass
+	TrustedProxies            []string
+
+	SkipMethods           []string
+	AggregateMethods      []string
+	LongPollRoutes        []string
+	UploadRoutes          []string
+	GraphQLRoutes         []string
+	GraphQLOperationLimit int
+
+	TenantExtractor func(*http.Request) string
+	TenantLimit     int
+
+	RateLimits        []RouteRateLimit
+	ShedInflight      int
+	ShedRouteInflight map[string]int
+	ConcurrencyLimits []RouteConcurrencyLimit
+	CacheRoutes       map[string]time.Duration
+	CacheMaxEntries   int
+	Compression       bool
+	MaxBodySizes      map[string]int64
+	CORS              *CORSConfig
+
+	Registerer  prometheus.Registerer
+	ConstLabels prometheus.Labels
+	InfoLabels  prometheus.Labels
+	Canary      bool
+	Gatherer    prometheus.Gatherer
+	Gatherers   []prometheus.Gatherer
+
+	MeterProvider  metric.MeterProvider
+	TracerProvider trace.TracerProvider
+	Recorders      []Recorder
+	AccessLogger   func(AccessLogEntry)
+	OnObserve      []func(RouteInfo)
+	EventBuffer    int
+	Logger         Logger
+	Clock          Clock
+
+	SlowRequestThreshold time.Duration
+	DebugObservations    bool
+	TimingBreakdown      bool
+	RequestIDHeader      string
 }
 
 func Namespace(ns string) func(*MuxProm) {
@@ -80,81 +236,795 @@ func MetricsRouteName(rn string) func(*MuxProm) {
 	}
 }
 
+func TogglePath(p string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.TogglePath = p
+	}
+}
+
+func ToggleRouteName(rn string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ToggleRouteName = rn
+	}
+}
+
+func LandingPage(path string, links ...LandingLink) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.LandingPath = path
+		prom.LandingLinks = append(prom.LandingLinks, links...)
+	}
+}
+
+func LandingRouteName(rn string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.LandingRouteName = rn
+	}
+}
+
 func DurationBucket(db []float64) func(*MuxProm) {
 	return func(prom *MuxProm) {
 		prom.DurationBucket = db
 	}
 }
 
+func BucketsByRoute(buckets map[string][]float64) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.BucketsByRoute = buckets
+	}
+}
+
+func BucketTuning(window time.Duration) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.BucketTuningWindow = window
+	}
+}
+
 func RespSizeBucket(rsb []float64) func(*MuxProm) {
 	return func(prom *MuxProm) {
 		prom.RespSizeBucket = rsb
 	}
 }
 
+func ScrapeCacheTTL(ttl time.Duration) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ScrapeCacheTTL = ttl
+	}
+}
+
+func PushDeleteOnStop(d bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.PushDeleteOnStop = d
+	}
+}
+
+func SLOs(slos ...SLO) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.SLOs = append(prom.SLOs, slos...)
+	}
+}
+
+func StateFile(filename string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.StateFile = filename
+	}
+}
+
+func SchemaVersion(v int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.SchemaVersion = v
+	}
+}
+
+func LegacyMetricNames(l bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.LegacyMetricNames = l
+	}
+}
+
+func ExcludeNotModifiedSize(e bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ExcludeNotModifiedSize = e
+	}
+}
+
+func RouteCardinalityThreshold(n int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.RouteCardinalityThreshold = n
+	}
+}
+
+func ClassifyUserAgent(f func(r *http.Request) string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ClassifyUserAgent = f
+	}
+}
+
+func ClassifyError(f func(r *http.Request, status int) string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ClassifyError = f
+	}
+}
+
+func GraphQLRoutes(routes ...string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.GraphQLRoutes = append(prom.GraphQLRoutes, routes...)
+	}
+}
+
+func GraphQLOperationLimit(n int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.GraphQLOperationLimit = n
+	}
+}
+
+func TenantExtractor(f func(*http.Request) string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.TenantExtractor = f
+	}
+}
+
+func TenantLimit(n int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.TenantLimit = n
+	}
+}
+
+func RateLimit(route string, rate float64, burst int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.RateLimits = append(prom.RateLimits, RouteRateLimit{Route: route, Rate: rate, Burst: burst})
+	}
+}
+
+func ShedInflight(limit int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ShedInflight = limit
+	}
+}
+
+func ShedRouteInflight(route string, limit int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		if prom.ShedRouteInflight == nil {
+			prom.ShedRouteInflight = make(map[string]int)
+		}
+		prom.ShedRouteInflight[route] = limit
+	}
+}
+
+func ConcurrencyLimit(route string, limit int, queue int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ConcurrencyLimits = append(prom.ConcurrencyLimits, RouteConcurrencyLimit{Route: route, Limit: limit, Queue: queue})
+	}
+}
+
+func CacheRoute(route string, ttl time.Duration) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		if prom.CacheRoutes == nil {
+			prom.CacheRoutes = make(map[string]time.Duration)
+		}
+		prom.CacheRoutes[route] = ttl
+	}
+}
+
+func CacheMaxEntries(n int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.CacheMaxEntries = n
+	}
+}
+
+func Compression(c bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Compression = c
+	}
+}
+
+func MaxBodySize(route string, bytes int64) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		if prom.MaxBodySizes == nil {
+			prom.MaxBodySizes = make(map[string]int64)
+		}
+		prom.MaxBodySizes[route] = bytes
+	}
+}
+
+func CORS(cfg CORSConfig) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.CORS = &cfg
+	}
+}
+
+func Exemplars(e bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Exemplars = e
+	}
+}
+
+func ExemplarMinDuration(d time.Duration) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ExemplarMinDuration = d
+	}
+}
+
+func ExemplarSampleRate(n int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ExemplarSampleRate = n
+	}
+}
+
+func ConstLabels(labels prometheus.Labels) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		if prom.ConstLabels == nil {
+			prom.ConstLabels = prometheus.Labels{}
+		}
+		for name, value := range labels {
+			prom.ConstLabels[name] = value
+		}
+	}
+}
+
+func Registerer(r prometheus.Registerer) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Registerer = r
+	}
+}
+
+func Gatherer(g prometheus.Gatherer) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Gatherer = g
+	}
+}
+
+func WithGatherers(gs ...prometheus.Gatherer) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Gatherers = append(prom.Gatherers, gs...)
+	}
+}
+
+func Registry(reg *prometheus.Registry) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Registerer = reg
+		prom.Gatherer = reg
+	}
+}
+
+func MeterProvider(mp metric.MeterProvider) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.MeterProvider = mp
+	}
+}
+
+func TracerProvider(tp trace.TracerProvider) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.TracerProvider = tp
+	}
+}
+
+func WithRecorders(rs ...Recorder) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Recorders = append(prom.Recorders, rs...)
+	}
+}
+
+func AccessLogger(al func(AccessLogEntry)) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.AccessLogger = al
+	}
+}
+
+func OnObserve(f func(RouteInfo)) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.OnObserve = append(prom.OnObserve, f)
+	}
+}
+
+func EventBuffer(n int) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.EventBuffer = n
+	}
+}
+
+func WithLogger(l Logger) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Logger = l
+	}
+}
+
+func WithClock(c Clock) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.Clock = c
+	}
+}
+
+func SlowRequestThreshold(d time.Duration) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.SlowRequestThreshold = d
+	}
+}
+
+func DebugObservations(d bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.DebugObservations = d
+	}
+}
+
+func RequestIDHeader(h string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.RequestIDHeader = h
+	}
+}
+
+func TimingBreakdown(tb bool) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.TimingBreakdown = tb
+	}
+}
+
 func Router(r *mux.Router) func(*MuxProm) {
 	return func(prom *MuxProm) {
 		prom.Router = r
 	}
 }
 
-func New(options ...func(prom *MuxProm)) *MuxProm {
-	p := &MuxProm{
-		Namespace:        defaultNamespace,
-		MetricsPath:      defaultMetricsPath,
-		MetricsRouteName: defaultMetricsRouteName,
-		DurationBucket:   defaultDurationBucket,
-		RespSizeBucket:   defaultRespSizeBucket,
+func ServeMux(sm *http.ServeMux) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.ServeMux = sm
 	}
+}
+
+func RouteLabeler(rl func(*http.Request) string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.RouteLabeler = rl
+	}
+}
+
+func defaults() *MuxProm {
+	return &MuxProm{
+		Namespace:                 defaultNamespace,
+		MetricsPath:               defaultMetricsPath,
+		MetricsRouteName:          defaultMetricsRouteName,
+		ToggleRouteName:           defaultToggleRouteName,
+		LandingRouteName:          defaultLandingRouteName,
+		StatsRouteName:            defaultStatsRouteName,
+		DurationBucket:            defaultDurationBucket,
+		RespSizeBucket:            defaultRespSizeBucket,
+		GraphQLOperationLimit:     defaultGraphQLOperationLimit,
+		TenantLimit:               defaultTenantLimit,
+		CacheMaxEntries:           defaultCacheMaxEntries,
+		RouteCardinalityThreshold: defaultRouteCardinalityThreshold,
+		SchemaVersion:             SchemaV1,
+		Registerer:                prometheus.DefaultRegisterer,
+		Gatherer:                  prometheus.DefaultGatherer,
+		Logger:                    defaultLogger(),
+		Clock:                     systemClock{},
+	}
+}
+
+func New(options ...func(prom *MuxProm)) (*MuxProm, error) {
+	p := defaults()
 	for _, option := range options {
 		option(p)
 	}
-	p.init()
+	if err := validSchemaVersion(p.SchemaVersion); err != nil {
+		return nil, err
+	}
+	if err := validSLOs(p.SLOs); err != nil {
+		return nil, err
+	}
+	if err := validConcurrencyLimits(p.ConcurrencyLimits); err != nil {
+		return nil, err
+	}
+	for _, s := range p.SLOs {
+		if len(s.Networks) > 0 && len(p.NetworkClasses) == 0 {
+			return nil, fmt.Errorf("muxprom: SLO %q selects networks but no NetworkClasses are configured", s.Name)
+		}
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
 
-	if p.Router != nil {
-		p.Router.
-			Name(p.MetricsRouteName).
-			Methods("GET").
-			Path(p.MetricsPath).
-			Handler(promhttp.Handler())
-	} else {
-		log.Fatal("You need to set Router")
+	if p.Router != nil || p.ServeMux != nil {
+		routers.Lock()
+		p.metricsMount = p.mountRoute(p.MetricsRouteName, p.MetricsPath, p.Handler(), "GET")
+		if p.TogglePath != "" {
+			p.mountRoute(p.ToggleRouteName, p.TogglePath, http.HandlerFunc(p.toggleHandler), "GET", "POST")
+		}
+		if p.LandingPath != "" {
+			p.mountRoute(p.LandingRouteName, p.LandingPath, http.HandlerFunc(p.landingHandler), "GET")
+		}
+		if p.StatsPath != "" {
+			p.mountRoute(p.StatsRouteName, p.StatsPath, http.HandlerFunc(p.statsHandler), "GET")
+		}
+		routers.Unlock()
+	}
+	if p.Router == nil && p.ServeMux != nil && p.RouteLabeler == nil {
+		p.RouteLabeler = ServeMuxRouteLabeler(p.ServeMux)
+	}
+	manual := p.RouteLabeler == nil && p.Router == nil && p.ServeMux == nil
+	if p.RouteLabeler == nil && p.SchemaVersion >= SchemaV2 {
+		p.RouteLabeler = MuxRouteTemplateLabeler
+	} else if p.RouteLabeler == nil {
+		p.RouteLabeler = MuxRouteLabeler
+	}
+	if manual {
+		p.RouteLabeler = unmatchedAsUnknown(p.RouteLabeler)
+	}
+	p.policyLabeler = p.RouteLabeler
+
+	return p, nil
+}
+
+func (prom *MuxProm) Instrument() error {
+	if prom.Router == nil {
+		return errors.New("muxprom: no router to instrument, wrap handlers with Middleware instead")
+	}
+	routers.Lock()
+	defer routers.Unlock()
+	if prom.isClosed() {
+		return errors.New("muxprom: closed")
+	}
+	if err := claimRouter(prom.Router, prom); err != nil {
+		return err
+	}
+	prom.Router.Use(prom.Middleware)
+	prom.Router.NotFoundHandler = WrapNotFoundHandler(prom.Router.NotFoundHandler, prom.Middleware)
+	prom.Router.MethodNotAllowedHandler = WrapMethodNotAllowedHandler(prom.Router.MethodNotAllowedHandler, prom.Middleware)
+	return nil
+}
+
+func (prom *MuxProm) InstrumentRouter(sub *mux.Router) error {
+	if sub == nil {
+		return errors.New("muxprom: nil router")
+	}
+	routers.Lock()
+	defer routers.Unlock()
+	if prom.isClosed() {
+		return errors.New("muxprom: closed")
+	}
+	if err := claimRouter(sub, prom); err != nil {
+		return err
 	}
+	// Subrouters fall through to the parent's NotFoundHandler, so only the
+	// middleware is added.
+	sub.Use(prom.Middleware)
+	prom.subrouters = append(prom.subrouters, sub)
+	return nil
+}
 
-	return p
+func (prom *MuxProm) gatherer() prometheus.Gatherer {
+	g := prom.Gatherer
+	if len(prom.Gatherers) > 0 {
+		g = append(prometheus.Gatherers{prom.Gatherer}, prom.Gatherers...)
+	}
+	if prom.restored != nil {
+		g = restoredGatherer{gatherer: g, state: prom.restored}
+	}
+	return g
 }
 
-func (prom *MuxProm) Instrument() {
-	prom.Router.Use(prom.middleware)
-	prom.Router.NotFoundHandler = WrapNotFoundHandler(prom.Router.NotFoundHandler, prom.middleware)
-	prom.Router.MethodNotAllowedHandler = WrapMethodNotAllowedHandler(prom.Router.MethodNotAllowedHandler, prom.middleware)
+func (prom *MuxProm) Handler() http.Handler {
+	if prom.tenants != nil {
+		return prom.tenants.handler(prom.handler(), promhttp.HandlerOpts{EnableOpenMetrics: prom.Exemplars})
+	}
+	return prom.handler()
+}
+
+func (prom *MuxProm) handler() http.Handler {
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: prom.Exemplars}
+	if prom.ScrapeCacheTTL > 0 {
+		return promhttp.HandlerFor(&cachingGatherer{gatherer: prom.gatherer(), ttl: prom.ScrapeCacheTTL}, opts)
+	}
+	if prom.Gatherer == prometheus.DefaultGatherer && len(prom.Gatherers) == 0 && prom.restored == nil {
+		if !prom.Exemplars {
+			return promhttp.Handler()
+		}
+		return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prom.Gatherer, opts))
+	}
+	return promhttp.HandlerFor(prom.gatherer(), opts)
 }
 
-func (prom *MuxProm) middleware(next http.Handler) http.Handler {
+func (prom *MuxProm) Describe(ch chan<- *prometheus.Desc) {
+	prom.reqInFlight.Describe(ch)
+	prom.reqDurationHistogram.Describe(ch)
+	prom.reqRespSizeHistogram.Describe(ch)
+	for _, c := range prom.collectors {
+		c.Describe(ch)
+	}
+}
+
+func (prom *MuxProm) Collect(ch chan<- prometheus.Metric) {
+	prom.reqInFlight.Collect(ch)
+	prom.reqDurationHistogram.Collect(ch)
+	prom.reqRespSizeHistogram.Collect(ch)
+	for _, c := range prom.collectors {
+		c.Collect(ch)
+	}
+}
+
+func (prom *MuxProm) Enable() {
+	atomic.StoreInt32(&prom.disabled, 0)
+}
+
+func (prom *MuxProm) Disable() {
+	atomic.StoreInt32(&prom.disabled, 1)
+}
+
+func (prom *MuxProm) Enabled() bool {
+	return atomic.LoadInt32(&prom.disabled) == 0
+}
+
+func (prom *MuxProm) toggleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be a boolean", http.StatusBadRequest)
+			return
+		}
+		if enabled {
+			prom.Enable()
+		} else {
+			prom.Disable()
+		}
+	}
+	fmt.Fprintf(w, "enabled=%t\n", prom.Enabled())
+}
+
+func (prom *MuxProm) isOwnRouteName(name string) bool {
+	return name == prom.MetricsRouteName ||
+		(prom.TogglePath != "" && name == prom.ToggleRouteName) ||
+		(prom.LandingPath != "" && name == prom.LandingRouteName) ||
+		(prom.StatsPath != "" && name == prom.StatsRouteName)
+}
+
+func (prom *MuxProm) isOwnRoute(r *http.Request) bool {
+	if route := mux.CurrentRoute(r); route != nil {
+		return prom.isOwnRouteName(route.GetName())
+	}
+	if prom.Router == nil {
+		path := r.URL.Path
+		return path == prom.MetricsPath ||
+			(prom.TogglePath != "" && path == prom.TogglePath) ||
+			(prom.LandingPath != "" && path == prom.LandingPath) ||
+			(prom.StatsPath != "" && path == prom.StatsPath)
+	}
+	return false
+}
+
+func MuxRouteLabeler(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return r.RequestURI
+	}
+	return route.GetName()
+}
+
+const unknownRoute = "unknown"
+
+// unmatchedAsUnknown labels requests outside a matched gorilla/mux route as
+// unknownRoute. Attached manually, the middleware may wrap any handler, and
+// the raw request URI would make the route label unbounded.
+func unmatchedAsUnknown(rl func(*http.Request) string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if mux.CurrentRoute(r) == nil {
+			return unknownRoute
+		}
+		return rl(r)
+	}
+}
+
+func ServeMuxRouteLabeler(sm *http.ServeMux) func(*http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := sm.Handler(r)
+		if pattern == "" {
+			return r.RequestURI
+		}
+		return pattern
+	}
+}
+
+type requestStateKey struct{}
+
+type requestState struct {
+	route        string
+	start        time.Time
+	handlerStart time.Time
+	handlerEnd   time.Time
+
+	longPolls     *longPolls
+	longPoll      bool
+	longPollRoute string
+
+	mu          sync.Mutex
+	checkpoints []checkpoint
+}
+
+func SetRouteLabel(ctx context.Context, route string) {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+		state.route = route
+	}
+}
+
+func (prom *MuxProm) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		route := mux.CurrentRoute(r)
-		if route != nil && route.GetName() == prom.MetricsRouteName {
+		markAudited(r)
+		if prom.isOwnRoute(r) {
 			next.ServeHTTP(w, r)
+		} else if !prom.Enabled() || prom.isClosed() || prom.skipMethods[r.Method] {
+			prom.serveAdmitted(w, r, prom.policyRoute(r), next)
 		} else {
-			var routeName string
-			if route == nil {
-				routeName = r.RequestURI
-			} else {
-				routeName = route.GetName()
+			policy := prom.policyRoute(r)
+			prom.mu.RLock()
+			routeName := prom.normalizeRouteLabel(prom.RouteLabeler(r))
+			if prom.aggregateMethods[r.Method] {
+				routeName = aggregatedRoute
+			}
+			prom.hits.record(r)
+			prom.inflight.Add(1)
+			prom.recorder.IncInflight(routeName, r.Method)
+			var operation string
+			if prom.graphql != nil && prom.graphql.matches(routeName) {
+				operation = prom.graphql.operation(r)
+			}
+			observing := len(prom.OnObserve) > 0 || prom.events != nil
+			upload := prom.uploads != nil && prom.uploads.routes[routeName]
+			var body *countingReadCloser
+			if (observing || upload) && r.Body != nil && r.Body != http.NoBody {
+				body = &countingReadCloser{ReadCloser: r.Body}
+				if upload {
+					body.clock = prom.Clock
+				}
+				r.Body = body
 			}
-			prom.reqInFlight.WithLabelValues(routeName, r.Method).Inc()
-			start := time.Now()
-			sw := statusWriter{ResponseWriter: w}
-			next.ServeHTTP(&sw, r)
-			duration := time.Since(start)
-			prom.reqDurationHistogram.WithLabelValues(routeName, r.Method, fmt.Sprintf("%d", sw.status)).Observe(duration.Seconds())
-			prom.reqRespSizeHistogram.WithLabelValues(routeName, r.Method, fmt.Sprintf("%d", sw.status)).Observe(float64(sw.length))
-			prom.reqInFlight.WithLabelValues(routeName, r.Method).Dec()
+			var span trace.Span
+			if prom.tracer != nil {
+				r, span = prom.startSpan(r, routeName)
+			}
+			start := prom.Clock.Now()
+			sw := statusWriter{ResponseWriter: w, timeWrites: prom.TimingBreakdown, clock: prom.Clock}
+			state := &requestState{route: routeName, start: start, longPolls: prom.longPolls}
+			if prom.longPolls.routes[routeName] {
+				prom.longPolls.start(state)
+			}
+			prom.mu.RUnlock()
+			panicked := true
+			defer func() {
+				if panicked {
+					// Record the request as failed; the panic itself keeps
+					// propagating to outer recovery middleware.
+					sw.status = http.StatusInternalServerError
+				} else if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+					sw.status = StatusDeadlineExceeded
+					prom.deadlineExceeded.WithLabelValues(state.route, r.Method).Inc()
+				} else if sw.status == 0 && sw.hijacked {
+					sw.status = http.StatusSwitchingProtocols
+				} else if sw.status == 0 {
+					// net/http sends an empty 200 response for handlers
+					// that return without writing anything.
+					sw.status = http.StatusOK
+					prom.emptyResponses.WithLabelValues(state.route, r.Method).Inc()
+				}
+				duration := prom.Clock.Since(start)
+				prom.mu.RLock()
+				var requestID string
+				if prom.RequestIDHeader != "" {
+					requestID = r.Header.Get(prom.RequestIDHeader)
+				}
+				ctx := prom.exemplarContext(r, requestID, duration)
+				if state.longPoll {
+					prom.longPolls.duration.WithLabelValues(state.route, r.Method, strconv.Itoa(sw.status)).Observe(duration.Seconds())
+					prom.longPolls.end(state)
+				} else {
+					prom.recorder.ObserveDuration(ctx, state.route, r.Method, sw.status, duration)
+				}
+				observeSize := sw.status != http.StatusNotModified || !prom.ExcludeNotModifiedSize
+				if observeSize {
+					prom.recorder.ObserveSize(ctx, state.route, r.Method, sw.status, sw.length)
+				}
+				if operation != "" {
+					prom.graphql.observe(routeName, operation, sw.status, duration)
+				}
+				if prom.tenants != nil {
+					prom.tenants.observe(prom.TenantExtractor(r), state.route, r.Method, sw.status, duration, sw.length, observeSize)
+				}
+				prom.recordRouteLabel(state.route)
+				prom.tooManyRequests.observe(state.route, sw.status, sw.Header(), prom.Clock.Now())
+				prom.conditional.observe(r, state.route, sw.status)
+				if upload && body != nil {
+					prom.uploads.observe(body, state.route, r.Method)
+				}
+				clientIP := prom.clientIP(r)
+				network := ""
+				if prom.networks != nil {
+					network = prom.networks.classify(clientIP)
+					prom.networks.requests.WithLabelValues(state.route, r.Method, strconv.Itoa(sw.status), network).Inc()
+				}
+				if prom.stats != nil && !state.longPoll {
+					prom.stats.observe(state.route, sw.status, duration)
+				}
+				if prom.slos != nil {
+					prom.slos.observe(state.route, network, sw.status, duration)
+				}
+				if prom.tuner != nil && !state.longPoll {
+					prom.tuner.observe(state.route, duration, prom.Clock.Now())
+				}
+				if prom.errorClasses != nil {
+					prom.errorClasses.observe(r, state.route, sw.status)
+				}
+				if prom.agentClasses != nil {
+					prom.agentClasses.observe(r, state.route)
+				}
+				if prom.negotiation != nil {
+					prom.negotiation.observe(r, state.route, sw.Header().Get("Content-Type"))
+				}
+				prom.observeCheckpoints(state, r.Method)
+				if prom.TimingBreakdown {
+					prom.observePhases(state, r.Method, start, duration, sw.writeTime)
+				}
+				prom.recorder.DecInflight(routeName, r.Method)
+				prom.inflight.Add(-1)
+				if span != nil {
+					endSpan(span, state.route, sw.status)
+				}
+				if prom.SlowRequestThreshold > 0 && duration > prom.SlowRequestThreshold {
+					prom.Logger.Warn("muxprom: slow request",
+						"route", state.route,
+						"method", r.Method,
+						"status", sw.status,
+						"duration", duration,
+						"bytes", sw.length,
+					)
+				}
+				if prom.DebugObservations {
+					prom.Logger.Debug("muxprom: observation",
+						"route", state.route,
+						"method", r.Method,
+						"status", sw.status,
+						"duration", duration,
+						"bytes", sw.length,
+					)
+				}
+				onObserve, accessLogger := prom.OnObserve, prom.AccessLogger
+				prom.mu.RUnlock()
+				if observing {
+					info := RouteInfo{
+						Route:        state.route,
+						Method:       r.Method,
+						RequestID:    requestID,
+						Status:       sw.status,
+						Duration:     duration,
+						ResponseSize: sw.length,
+					}
+					if body != nil {
+						info.RequestSize = body.length
+					}
+					for _, f := range onObserve {
+						f(info)
+					}
+					if prom.events != nil {
+						prom.events.publish(RequestEvent{Time: start, RouteInfo: info})
+					}
+				}
+				if accessLogger != nil {
+					accessLogger(AccessLogEntry{
+						Time:       start,
+						Route:      state.route,
+						Method:     r.Method,
+						Path:       r.URL.Path,
+						RequestID:  requestID,
+						Status:     sw.status,
+						Bytes:      sw.length,
+						Duration:   duration,
+						RemoteAddr: r.RemoteAddr,
+						ClientIP:   clientIPString(clientIP),
+						UserAgent:  r.UserAgent(),
+					})
+				}
+			}()
+			prom.serveAdmitted(&sw, r.WithContext(context.WithValue(r.Context(), requestStateKey{}, state)), policy, next)
+			panicked = false
 		}
 	})
 }
 
-func (prom *MuxProm) init() {
+func (prom *MuxProm) init() error {
 	prom.reqInFlight = *prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: prom.Namespace,
@@ -163,7 +1033,6 @@ func (prom *MuxProm) init() {
 		},
 		[]string{"route", "method"},
 	)
-	prometheus.MustRegister(prom.reqInFlight)
 
 	prom.reqDurationHistogram = *prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -174,18 +1043,365 @@ func (prom *MuxProm) init() {
 		},
 		[]string{"route", "method", "http_status"},
 	)
-	prometheus.MustRegister(prom.reqDurationHistogram)
 
 	prom.reqRespSizeHistogram = *prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: prom.Namespace,
-			Name:      "http_response_size",
+			Name:      sizeMetricName(prom.SchemaVersion, "http_response_size"),
 			Help:      "HTTP response size in bytes",
 			Buckets:   prom.RespSizeBucket,
 		},
 		[]string{"route", "method", "http_status"},
 	)
-	prometheus.MustRegister(prom.reqRespSizeHistogram)
+
+	if prom.LegacyMetricNames && prom.SchemaVersion >= SchemaV2 {
+		prom.reqRespSizeLegacy = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_response_size",
+				Help:      "HTTP response size in bytes (deprecated, use http_response_size_bytes)",
+				Buckets:   prom.RespSizeBucket,
+			},
+			[]string{"route", "method", "http_status"},
+		)
+		prom.collectors = append(prom.collectors, prom.reqRespSizeLegacy)
+	}
+
+	prom.routeDurations = make(map[string]*prometheus.HistogramVec, len(prom.BucketsByRoute))
+	for route, buckets := range prom.BucketsByRoute {
+		// Same descriptor as the regular duration histogram, so these
+		// series join it with their own buckets.
+		vec := prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_duration_seconds",
+				Help:      "HTTP request duration seconds",
+				Buckets:   buckets,
+			},
+			[]string{"route", "method", "http_status"},
+		)
+		prom.routeDurations[route] = vec
+		prom.collectors = append(prom.collectors, vec)
+	}
+
+	prom.reqStageHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: prom.Namespace,
+			Name:      "http_request_stage_duration_seconds",
+			Help:      "HTTP request duration seconds between checkpoints",
+			Buckets:   prom.DurationBucket,
+		},
+		[]string{"route", "method", "stage"},
+	)
+	prom.collectors = append(prom.collectors, prom.reqStageHistogram)
+
+	prom.collectors = append(prom.collectors, prom.newConfigInfo())
+
+	prom.unaccounted = newUnaccountedCounter(prom)
+	prom.collectors = append(prom.collectors, prom.unaccounted)
+
+	prom.draining = newDrainingGauge(prom)
+	prom.collectors = append(prom.collectors, prom.draining)
+
+	prom.emptyResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prom.Namespace,
+			Name:      "http_requests_empty_response_total",
+			Help:      "HTTP requests whose handler returned without writing a status or body",
+		},
+		[]string{"route", "method"},
+	)
+	prom.collectors = append(prom.collectors, prom.emptyResponses)
+
+	prom.deadlineExceeded = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prom.Namespace,
+			Name:      "http_requests_deadline_exceeded_total",
+			Help:      "HTTP requests whose context deadline was exceeded before the handler returned",
+		},
+		[]string{"route", "method"},
+	)
+	prom.collectors = append(prom.collectors, prom.deadlineExceeded)
+
+	prom.tooManyRequests = newTooManyRequests(prom)
+	if prom.StatsPath != "" {
+		prom.stats = newSlidingStats(prom)
+	}
+
+	prom.skipMethods = methodSet(prom.SkipMethods)
+	prom.aggregateMethods = methodSet(prom.AggregateMethods)
+	prom.conditional = newConditionalRequests(prom)
+	if len(prom.UploadRoutes) > 0 {
+		prom.uploads = newUploads(prom)
+		prom.collectors = append(prom.collectors, prom.uploads.duration, prom.uploads.throughput)
+	}
+
+	prom.longPolls = newLongPolls(prom)
+	prom.collectors = append(prom.collectors, prom.longPolls.duration, prom.longPolls.active)
+	prom.collectors = append(prom.collectors, prom.conditional.conditional, prom.conditional.notModified)
+	if prom.BucketTuningWindow > 0 {
+		prom.tuner = newBucketTuner(prom)
+	}
+	prom.collectors = append(prom.collectors, prom.tooManyRequests.responses, prom.tooManyRequests.retryAfter)
+
+	prom.breakers = newBreakerMetrics(prom)
+	prom.collectors = append(prom.collectors, prom.breakers.state, prom.breakers.trips)
+
+	prom.hits.seen = make(map[string]struct{})
+	prom.cardinality.seen = make(map[string]struct{})
+	prom.collectors = append(prom.collectors, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: prom.Namespace,
+			Name:      "route_cardinality",
+			Help:      "Number of distinct route label values observed",
+		},
+		func() float64 {
+			return float64(prom.RouteCardinality())
+		},
+	))
+	prom.collectors = append(prom.collectors, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: prom.Namespace,
+			Name:      "routes_never_hit",
+			Help:      "Number of registered routes that have not received any request",
+		},
+		func() float64 {
+			return float64(len(prom.Coverage().NeverHit))
+		},
+	))
+
+	if prom.TimingBreakdown {
+		prom.reqPhaseHistogram = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_phase_duration_seconds",
+				Help:      "HTTP request duration seconds by phase",
+				Buckets:   prom.DurationBucket,
+			},
+			[]string{"route", "method", "phase"},
+		)
+		prom.collectors = append(prom.collectors, prom.reqPhaseHistogram)
+	}
+
+	if prom.EventBuffer > 0 {
+		prom.events = &eventStream{
+			ch: make(chan RequestEvent, prom.EventBuffer),
+			dropped: prometheus.NewCounter(prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "events_dropped_total",
+				Help:      "Request events dropped because the event buffer was full",
+			}),
+		}
+		prom.collectors = append(prom.collectors, prom.events.dropped)
+	}
+
+	if prom.ClassifyError != nil {
+		prom.errorClasses = newErrorClasses(prom)
+		prom.collectors = append(prom.collectors, prom.errorClasses.errors)
+	}
+
+	trustedProxies, err := parseTrustedProxies(prom.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	prom.trustedProxies = trustedProxies
+
+	if len(prom.NetworkClasses) > 0 {
+		networks, err := newNetworkClassifier(prom)
+		if err != nil {
+			return err
+		}
+		prom.networks = networks
+		prom.collectors = append(prom.collectors, prom.networks.requests)
+	}
+
+	if prom.ContentNegotiation {
+		prom.negotiation = newContentNegotiation(prom)
+		prom.collectors = append(prom.collectors, prom.negotiation.requests)
+	}
+
+	if prom.ClassifyUserAgent != nil {
+		prom.agentClasses = newAgentClasses(prom)
+		prom.collectors = append(prom.collectors, prom.agentClasses.requests)
+	}
+
+	if len(prom.GraphQLRoutes) > 0 {
+		prom.graphql = newGraphQLOperations(prom)
+		prom.collectors = append(prom.collectors, prom.graphql.duration)
+	}
+
+	if prom.ShedInflight > 0 || len(prom.ShedRouteInflight) > 0 {
+		prom.shedder = newLoadShedder(prom)
+		prom.collectors = append(prom.collectors, prom.shedder.shed)
+	}
+
+	if len(prom.ConcurrencyLimits) > 0 {
+		prom.concurrency = newConcurrencyLimiters(prom)
+		prom.collectors = append(prom.collectors, prom.concurrency.depth, prom.concurrency.wait, prom.concurrency.rejected)
+	}
+
+	if len(prom.CacheRoutes) > 0 {
+		prom.cache = newResponseCache(prom)
+		prom.collectors = append(prom.collectors, prom.cache.hits, prom.cache.misses, prom.cache.saved, prom.cache.evictions)
+	}
+
+	if prom.CORS != nil {
+		prom.cors = newCORSHandler(prom)
+		prom.collectors = append(prom.collectors, prom.cors.requests)
+	}
+
+	if len(prom.MaxBodySizes) > 0 {
+		prom.bodyRejected = newBodyRejected(prom)
+		prom.collectors = append(prom.collectors, prom.bodyRejected)
+	}
+
+	if prom.Compression {
+		prom.compression = newCompressionMetrics(prom)
+		prom.collectors = append(prom.collectors, prom.compression.uncompressed, prom.compression.ratio)
+	}
+
+	if len(prom.SLOs) > 0 {
+		prom.slos = newSLOTracker(prom)
+		prom.collectors = append(prom.collectors, prom.slos.requests, prom.slos.errors, prom.slos.slow, prom.slos.objective)
+	}
+
+	if prom.StateFile != "" {
+		restored, snapshotTime, err := loadState(prom.StateFile)
+		if err != nil {
+			return err
+		}
+		prom.restored = restored
+		restoredAt := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: prom.Namespace,
+			Name:      "state_restored_snapshot_timestamp_seconds",
+			Help:      "Time the restored counter and histogram snapshot was saved, 0 if nothing was restored",
+		})
+		restoredAt.Set(snapshotTime)
+		prom.collectors = append(prom.collectors, restoredAt)
+	}
+
+	if len(prom.RateLimits) > 0 {
+		prom.rateLimiters = newRateLimiters(prom)
+		prom.collectors = append(prom.collectors, prom.rateLimiters.decisions)
+	}
+
+	if prom.TenantExtractor != nil {
+		prom.tenants = newTenantRegistries(prom)
+		prom.collectors = append(prom.collectors, prom.tenants.rejected, prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: prom.Namespace,
+				Name:      "tenants",
+				Help:      "Number of tenants with their own metrics registry",
+			},
+			func() float64 {
+				return float64(prom.tenants.count())
+			},
+		))
+	}
+
+	prom.registered = prom
+	if prom.Registerer != nil {
+		if err := prom.wrapRegisterer(prom.Registerer).Register(prom); err != nil {
+			// An identically configured MuxProm is already registered (e.g. a
+			// second instance for another router): record into its collectors.
+			are, ok := err.(prometheus.AlreadyRegisteredError)
+			if !ok {
+				return fmt.Errorf("muxprom: registering collectors failed: %w", err)
+			}
+			existing, ok := are.ExistingCollector.(*MuxProm)
+			if !ok {
+				return fmt.Errorf("muxprom: registering collectors failed: %w", err)
+			}
+			prom.adopt(existing)
+		}
+	}
+
+	if prom.TracerProvider != nil {
+		prom.tracer = prom.TracerProvider.Tracer(otelInstrumentationName)
+	}
+
+	recorders := MultiRecorder{prometheusRecorder{prom: prom.registered}}
+	if prom.MeterProvider != nil {
+		o, err := newOtelInstruments(prom.MeterProvider, prom.DurationBucket, prom.RespSizeBucket)
+		if err != nil {
+			return fmt.Errorf("muxprom: creating OpenTelemetry instruments failed: %w", err)
+		}
+		recorders = append(recorders, o)
+	}
+	for _, r := range prom.Recorders {
+		if d, ok := r.(*DogStatsDRecorder); ok {
+			d.useSchema(prom.SchemaVersion, prom.LegacyMetricNames)
+		}
+	}
+	prom.recorder = append(recorders, prom.Recorders...)
+	return nil
+}
+
+// adopt makes prom record into the collectors of existing, which has the
+// same descriptors and is registered in its place. Limits, caches and other
+// state stay per instance; only the metrics are shared.
+func (prom *MuxProm) adopt(existing *MuxProm) {
+	prom.registered = existing
+	prom.reqPhaseHistogram = existing.reqPhaseHistogram
+	prom.reqStageHistogram = existing.reqStageHistogram
+	prom.reqRespSizeLegacy = existing.reqRespSizeLegacy
+	prom.routeDurations = existing.routeDurations
+	prom.unaccounted = existing.unaccounted
+	prom.draining = existing.draining
+	prom.emptyResponses = existing.emptyResponses
+	prom.deadlineExceeded = existing.deadlineExceeded
+	prom.bodyRejected = existing.bodyRejected
+	prom.tenants = existing.tenants
+
+	prom.tooManyRequests = existing.tooManyRequests
+	prom.conditional = existing.conditional
+	prom.breakers = existing.breakers
+	prom.longPolls.duration, prom.longPolls.active = existing.longPolls.duration, existing.longPolls.active
+	if prom.uploads != nil {
+		prom.uploads.duration, prom.uploads.throughput = existing.uploads.duration, existing.uploads.throughput
+	}
+	if prom.events != nil {
+		prom.events.dropped = existing.events.dropped
+	}
+	if prom.errorClasses != nil {
+		prom.errorClasses.errors = existing.errorClasses.errors
+	}
+	if prom.networks != nil {
+		prom.networks.requests = existing.networks.requests
+	}
+	if prom.negotiation != nil {
+		prom.negotiation = existing.negotiation
+	}
+	if prom.agentClasses != nil {
+		prom.agentClasses.requests = existing.agentClasses.requests
+	}
+	if prom.graphql != nil {
+		prom.graphql.duration = existing.graphql.duration
+	}
+	if prom.shedder != nil {
+		prom.shedder.shed = existing.shedder.shed
+	}
+	if prom.concurrency != nil {
+		prom.concurrency.depth = existing.concurrency.depth
+		prom.concurrency.wait = existing.concurrency.wait
+		prom.concurrency.rejected = existing.concurrency.rejected
+	}
+	if prom.cache != nil {
+		prom.cache.hits, prom.cache.misses = existing.cache.hits, existing.cache.misses
+		prom.cache.saved, prom.cache.evictions = existing.cache.saved, existing.cache.evictions
+	}
+	if prom.cors != nil {
+		prom.cors.requests = existing.cors.requests
+	}
+	if prom.compression != nil {
+		prom.compression.uncompressed, prom.compression.ratio = existing.compression.uncompressed, existing.compression.ratio
+	}
+	if prom.slos != nil {
+		prom.slos.requests, prom.slos.errors, prom.slos.slow = existing.slos.requests, existing.slos.errors, existing.slos.slow
+	}
+	if prom.rateLimiters != nil {
+		prom.rateLimiters.decisions = existing.rateLimiters.decisions
+	}
 }
 
 func WrapNotFoundHandler(h http.Handler, m mux.MiddlewareFunc) http.Handler {
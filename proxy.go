@@ -0,0 +1,89 @@
+package muxprom
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+func TrustedProxies(cidrs ...string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.TrustedProxies = append(prom.TrustedProxies, cidrs...)
+	}
+}
+
+func (prom *MuxProm) trusted(ip net.IP) bool {
+	for _, n := range prom.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the peer address, unless the peer is a trusted proxy: then it is
+// the closest untrusted hop from Forwarded or X-Forwarded-For.
+func (prom *MuxProm) clientIP(r *http.Request) net.IP {
+	peer := remoteIP(r)
+	if peer == nil || !prom.trusted(peer) {
+		return peer
+	}
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := hops[i]
+		if ip == nil {
+			// Obfuscated or unknown hops cannot be attributed any further.
+			return peer
+		}
+		if !prom.trusted(ip) || i == 0 {
+			return ip
+		}
+	}
+	return peer
+}
+
+func forwardedFor(h http.Header) []net.IP {
+	var hops []net.IP
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		for _, v := range values {
+			for _, element := range strings.Split(v, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(name, "for") {
+						hops = append(hops, parseHop(strings.Trim(value, `"`)))
+					}
+				}
+			}
+		}
+		return hops
+	}
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, parseHop(strings.TrimSpace(hop)))
+		}
+	}
+	return hops
+}
+
+func parseHop(hop string) net.IP {
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
+
+func clientIPString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func parseTrustedProxies(cidrs []string) ([]*net.IPNet, error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, fmt.Errorf("muxprom: trusted proxies: %w", err)
+	}
+	return nets, nil
+}
@@ -0,0 +1,18 @@
+package muxprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+func (prom *MuxProm) StartPush(gatewayURL string, jobName string, interval time.Duration) (stop func() error) {
+	pusher := push.New(gatewayURL, jobName).Gatherer(prom.gatherer())
+	return startPeriodic(prom.Logger, interval, "pushing to "+gatewayURL, pusher.Push, func() error {
+		err := pusher.Push()
+		if err == nil && prom.PushDeleteOnStop {
+			err = pusher.Delete()
+		}
+		return err
+	})
+}
@@ -0,0 +1,83 @@
+package muxprom
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type RouteRateLimit struct {
+	Route string
+	Rate  float64
+	Burst int
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) allow(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 && b.rate <= 0 {
+		return false, 0
+	}
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+type rateLimiters struct {
+	buckets   map[string]*tokenBucket
+	decisions *prometheus.CounterVec
+}
+
+func newRateLimiters(prom *MuxProm) *rateLimiters {
+	l := &rateLimiters{
+		buckets: make(map[string]*tokenBucket),
+		decisions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "rate_limit_decisions_total",
+				Help:      "Rate limiter decisions by route",
+			},
+			[]string{"route", "decision"},
+		),
+	}
+	for _, rl := range prom.RateLimits {
+		burst := float64(rl.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		l.buckets[rl.Route] = &tokenBucket{rate: rl.Rate, burst: burst, tokens: burst}
+	}
+	return l
+}
+
+func (l *rateLimiters) allow(route string, now time.Time) (bool, time.Duration) {
+	b, ok := l.buckets[route]
+	if !ok {
+		return true, 0
+	}
+	allowed, wait := b.allow(now)
+	if !allowed {
+		l.decisions.WithLabelValues(route, "limited").Inc()
+		return false, wait
+	}
+	l.decisions.WithLabelValues(route, "allowed").Inc()
+	return true, 0
+}
@@ -0,0 +1,47 @@
+package muxprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       float64
+		burst      int
+		want       []int
+		retryAfter string
+		allowed    float64
+		limited    float64
+	}{
+		{name: "burst", rate: 0, burst: 2, want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, allowed: 2, limited: 1},
+		{name: "refilling", rate: 0.5, burst: 1, want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}, retryAfter: "2", allowed: 1, limited: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, prom := newPolicyRouter(t, RateLimit("Users", tt.rate, tt.burst), WithClock(fixedClock{}))
+			var rec *httptest.ResponseRecorder
+			for i, want := range tt.want {
+				rec = httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest("GET", "/users/", nil))
+				if rec.Code != want {
+					t.Fatalf("request %d: status %d, want %d", i, rec.Code, want)
+				}
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After %q, want %q", got, tt.retryAfter)
+			}
+			if got := metricValue(t, prom, "rate_limit_decisions_total", "route", "Users", "decision", "allowed"); got != tt.allowed {
+				t.Errorf("allowed decisions %v, want %v", got, tt.allowed)
+			}
+			if got := metricValue(t, prom, "rate_limit_decisions_total", "route", "Users", "decision", "limited"); got != tt.limited {
+				t.Errorf("limited decisions %v, want %v", got, tt.limited)
+			}
+			if got := metricValue(t, prom, "http_responses_too_many_requests_total", "route", "Users"); got != tt.limited {
+				t.Errorf("429 responses %v, want %v", got, tt.limited)
+			}
+		})
+	}
+}
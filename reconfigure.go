@@ -0,0 +1,97 @@
+package muxprom
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var reconfigurable = map[string]bool{
+	"RouteLabeler":              true,
+	"StripTrailingSlash":        true,
+	"LowercaseRouteLabels":      true,
+	"LandingLinks":              true,
+	"ExemplarMinDuration":       true,
+	"ExemplarSampleRate":        true,
+	"RouteCardinalityThreshold": true,
+	"AccessLogger":              true,
+	"OnObserve":                 true,
+	"SlowRequestThreshold":      true,
+	"DebugObservations":         true,
+	"RequestIDHeader":           true,
+}
+
+func (prom *MuxProm) Reconfigure(options ...func(prom *MuxProm)) error {
+	prom.mu.Lock()
+	defer prom.mu.Unlock()
+
+	next := &MuxProm{}
+	copyConfig(next, prom)
+	for _, option := range options {
+		option(next)
+	}
+
+	cur, upd := reflect.ValueOf(prom).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < cur.NumField(); i++ {
+		field := cur.Type().Field(i)
+		if !field.IsExported() || reconfigurable[field.Name] {
+			continue
+		}
+		if !sameConfigValue(cur.Field(i), upd.Field(i)) {
+			return fmt.Errorf("muxprom: %s cannot be changed after New", field.Name)
+		}
+	}
+	if next.RouteLabeler == nil {
+		return errors.New("muxprom: RouteLabeler cannot be nil")
+	}
+	dst := reflect.ValueOf(prom).Elem()
+	for name := range reconfigurable {
+		dst.FieldByName(name).Set(upd.FieldByName(name))
+	}
+	return nil
+}
+
+func copyConfig(dst *MuxProm, src *MuxProm) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		if !d.Type().Field(i).IsExported() {
+			continue
+		}
+		// Options such as ShedRouteInflight add to maps in place, so maps are
+		// copied to keep them from writing into the live configuration.
+		if f := s.Field(i); f.Kind() == reflect.Map && !f.IsNil() {
+			m := reflect.MakeMapWithSize(f.Type(), f.Len())
+			iter := f.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), iter.Value())
+			}
+			d.Field(i).Set(m)
+			continue
+		}
+		d.Field(i).Set(s.Field(i))
+	}
+}
+
+func sameConfigValue(a reflect.Value, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Map:
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	case reflect.Func, reflect.Slice, reflect.Ptr:
+		if a.Kind() == reflect.Slice && a.Len() != b.Len() {
+			return false
+		}
+		return a.Pointer() == b.Pointer()
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return sameConfigValue(a.Elem(), b.Elem())
+	}
+	if a.Type().Comparable() {
+		return a.Interface() == b.Interface()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -0,0 +1,67 @@
+package muxprom
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+type Recorder interface {
+	IncInflight(route string, method string)
+	DecInflight(route string, method string)
+	ObserveDuration(ctx context.Context, route string, method string, status int, d time.Duration)
+	ObserveSize(ctx context.Context, route string, method string, status int, bytes int)
+}
+
+type MultiRecorder []Recorder
+
+func (m MultiRecorder) IncInflight(route string, method string) {
+	for _, r := range m {
+		r.IncInflight(route, method)
+	}
+}
+
+func (m MultiRecorder) DecInflight(route string, method string) {
+	for _, r := range m {
+		r.DecInflight(route, method)
+	}
+}
+
+func (m MultiRecorder) ObserveDuration(ctx context.Context, route string, method string, status int, d time.Duration) {
+	for _, r := range m {
+		r.ObserveDuration(ctx, route, method, status, d)
+	}
+}
+
+func (m MultiRecorder) ObserveSize(ctx context.Context, route string, method string, status int, bytes int) {
+	for _, r := range m {
+		r.ObserveSize(ctx, route, method, status, bytes)
+	}
+}
+
+type prometheusRecorder struct {
+	prom *MuxProm
+}
+
+func (p prometheusRecorder) IncInflight(route string, method string) {
+	p.prom.reqInFlight.WithLabelValues(route, method).Inc()
+}
+
+func (p prometheusRecorder) DecInflight(route string, method string) {
+	p.prom.reqInFlight.WithLabelValues(route, method).Dec()
+}
+
+func (p prometheusRecorder) ObserveDuration(ctx context.Context, route string, method string, status int, d time.Duration) {
+	if vec, ok := p.prom.routeDurations[route]; ok {
+		observeWithExemplar(ctx, vec.WithLabelValues(route, method, strconv.Itoa(status)), d.Seconds())
+		return
+	}
+	observeWithExemplar(ctx, p.prom.reqDurationHistogram.WithLabelValues(route, method, strconv.Itoa(status)), d.Seconds())
+}
+
+func (p prometheusRecorder) ObserveSize(ctx context.Context, route string, method string, status int, bytes int) {
+	observeWithExemplar(ctx, p.prom.reqRespSizeHistogram.WithLabelValues(route, method, strconv.Itoa(status)), float64(bytes))
+	if p.prom.reqRespSizeLegacy != nil {
+		observeWithExemplar(ctx, p.prom.reqRespSizeLegacy.WithLabelValues(route, method, strconv.Itoa(status)), float64(bytes))
+	}
+}
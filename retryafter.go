@@ -0,0 +1,66 @@
+package muxprom
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var retryAfterBucket = []float64{1, 2, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+type tooManyRequests struct {
+	responses  *prometheus.CounterVec
+	retryAfter *prometheus.HistogramVec
+}
+
+func newTooManyRequests(prom *MuxProm) *tooManyRequests {
+	return &tooManyRequests{
+		responses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_responses_too_many_requests_total",
+				Help:      "HTTP 429 responses by route",
+			},
+			[]string{"route"},
+		),
+		retryAfter: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_response_retry_after_seconds",
+				Help:      "Retry-After values sent with HTTP 429 and 503 responses by route",
+				Buckets:   retryAfterBucket,
+			},
+			[]string{"route"},
+		),
+	}
+}
+
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func (t *tooManyRequests) observe(route string, status int, header http.Header, now time.Time) {
+	if status == http.StatusTooManyRequests {
+		t.responses.WithLabelValues(route).Inc()
+	} else if status != http.StatusServiceUnavailable {
+		return
+	}
+	if d, ok := parseRetryAfter(header.Get("Retry-After"), now); ok {
+		t.retryAfter.WithLabelValues(route).Observe(d.Seconds())
+	}
+}
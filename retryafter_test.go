@@ -0,0 +1,56 @@
+package muxprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		responses  float64
+		observed   float64
+	}{
+		{name: "too many requests", status: http.StatusTooManyRequests, retryAfter: "30", responses: 1, observed: 1},
+		{name: "too many requests without header", status: http.StatusTooManyRequests, responses: 1},
+		{name: "unavailable with date", status: http.StatusServiceUnavailable, retryAfter: fixedClock{}.Now().Add(time.Minute).Format(http.TimeFormat), observed: 1},
+		{name: "invalid header", status: http.StatusServiceUnavailable, retryAfter: "soon"},
+		{name: "ok", status: http.StatusOK, retryAfter: "30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Name("busy").Path("/busy").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			})
+			prom, err := New(Router(router), Registry(prometheus.NewRegistry()), WithClock(fixedClock{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := prom.Instrument(); err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", "/busy", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if got := metricValue(t, prom, "http_responses_too_many_requests_total", "route", "busy"); got != tt.responses {
+				t.Errorf("429 responses %v, want %v", got, tt.responses)
+			}
+			if got := metricValue(t, prom, "http_response_retry_after_seconds", "route", "busy"); got != tt.observed {
+				t.Errorf("Retry-After observations %v, want %v", got, tt.observed)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package muxprom
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type RouteSLO struct {
+	Route            string
+	Availability     float64
+	LatencyThreshold time.Duration
+	LatencyObjective float64
+}
+
+type RulesConfig struct {
+	Namespace      string
+	DurationBucket []float64
+	BucketsByRoute map[string][]float64
+	SLOs           []RouteSLO
+	Objectives     []SLO
+}
+
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type burnRateWindow struct {
+	long     string
+	short    string
+	span     time.Duration
+	budget   float64
+	severity string
+}
+
+var sloRuleWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"}
+
+// budget is the share of the error budget spent within span before the
+// alert fires; over a 30 day window these are burn rates 14.4, 6, 3 and 1.
+var burnRateWindows = []burnRateWindow{
+	{long: "1h", short: "5m", span: time.Hour, budget: 0.02, severity: "page"},
+	{long: "6h", short: "30m", span: 6 * time.Hour, budget: 0.05, severity: "page"},
+	{long: "1d", short: "2h", span: 24 * time.Hour, budget: 0.1, severity: "ticket"},
+	{long: "3d", short: "6h", span: 72 * time.Hour, budget: 0.1, severity: "ticket"},
+}
+
+func (prom *MuxProm) Rules(slos ...RouteSLO) ([]byte, error) {
+	return GenerateRules(RulesConfig{
+		Namespace:      prom.Namespace,
+		DurationBucket: prom.DurationBucket,
+		BucketsByRoute: prom.BucketsByRoute,
+		SLOs:           slos,
+		Objectives:     prom.SLOs,
+	})
+}
+
+func GenerateRules(cfg RulesConfig) ([]byte, error) {
+	if cfg.Namespace == "" {
+		cfg.Namespace = defaultNamespace
+	}
+	if cfg.DurationBucket == nil {
+		cfg.DurationBucket = defaultDurationBucket
+	}
+	duration := cfg.Namespace + "_http_request_duration_seconds"
+	errorsRecord := "route:" + cfg.Namespace + "_http_request_errors:ratio_rate"
+	slowRecord := "route:" + cfg.Namespace + "_http_request_slow:ratio_rate"
+
+	recording := ruleGroup{Name: cfg.Namespace + "-slo-recording"}
+	alerting := ruleGroup{Name: cfg.Namespace + "-slo-alerts"}
+	for _, slo := range cfg.SLOs {
+		sel := fmt.Sprintf("route=%q", slo.Route)
+
+		if slo.Availability > 0 {
+			for _, w := range sloRuleWindows {
+				recording.Rules = append(recording.Rules, rule{
+					Record: errorsRecord + w,
+					Expr: fmt.Sprintf(`sum by (route) (rate(%[1]s_count{%[2]s,http_status=~"5.."}[%[3]s])) / sum by (route) (rate(%[1]s_count{%[2]s}[%[3]s]))`,
+						duration, sel, w),
+				})
+			}
+			alerting.Rules = append(alerting.Rules, burnRateAlerts(
+				cfg.Namespace+"_availability_budget_burn", errorsRecord, sel, 1-slo.Availability, defaultSLOWindow,
+				fmt.Sprintf("Route %s is burning its %g%% availability error budget", slo.Route, slo.Availability*100),
+			)...)
+		}
+
+		if slo.LatencyThreshold > 0 && slo.LatencyObjective > 0 {
+			le := slo.LatencyThreshold.Seconds()
+			buckets, ok := cfg.BucketsByRoute[slo.Route]
+			if !ok {
+				buckets = cfg.DurationBucket
+			}
+			if !containsFloat(buckets, le) {
+				return nil, fmt.Errorf("latency threshold %s of route %s is not a duration bucket boundary", slo.LatencyThreshold, slo.Route)
+			}
+			for _, w := range sloRuleWindows {
+				recording.Rules = append(recording.Rules, rule{
+					Record: slowRecord + w,
+					Expr: fmt.Sprintf(`1 - sum by (route) (rate(%[1]s_bucket{%[2]s,le="%[3]s"}[%[4]s])) / sum by (route) (rate(%[1]s_count{%[2]s}[%[4]s]))`,
+						duration, sel, formatFloat(le), w),
+				})
+			}
+			alerting.Rules = append(alerting.Rules, burnRateAlerts(
+				cfg.Namespace+"_latency_budget_burn", slowRecord, sel, 1-slo.LatencyObjective, defaultSLOWindow,
+				fmt.Sprintf("Route %s is burning its latency error budget (%g%% of requests faster than %s)", slo.Route, slo.LatencyObjective*100, slo.LatencyThreshold),
+			)...)
+		}
+	}
+
+	if err := validSLOs(cfg.Objectives); err != nil {
+		return nil, err
+	}
+	sloErrorsRecord := "slo:" + cfg.Namespace + "_errors:ratio_rate"
+	sloSlowRecord := "slo:" + cfg.Namespace + "_slow:ratio_rate"
+	for _, slo := range cfg.Objectives {
+		matcher := fmt.Sprintf("slo=%q", slo.Name)
+		if slo.Availability > 0 {
+			for _, w := range sloRuleWindows {
+				recording.Rules = append(recording.Rules, rule{
+					Record: sloErrorsRecord + w,
+					Expr: fmt.Sprintf(`sum by (slo) (rate(%[1]s_slo_errors_total{%[2]s}[%[3]s])) / sum by (slo) (rate(%[1]s_slo_requests_total{%[2]s}[%[3]s]))`,
+						cfg.Namespace, matcher, w),
+				})
+			}
+			alerting.Rules = append(alerting.Rules, burnRateAlerts(
+				cfg.Namespace+"_slo_availability_budget_burn", sloErrorsRecord, matcher, 1-slo.Availability, slo.window(),
+				fmt.Sprintf("SLO %s is burning its %g%% availability error budget", slo.Name, slo.Availability*100),
+			)...)
+		}
+		if slo.LatencyObjective > 0 {
+			for _, w := range sloRuleWindows {
+				recording.Rules = append(recording.Rules, rule{
+					Record: sloSlowRecord + w,
+					Expr: fmt.Sprintf(`sum by (slo) (rate(%[1]s_slo_slow_requests_total{%[2]s}[%[3]s])) / sum by (slo) (rate(%[1]s_slo_requests_total{%[2]s}[%[3]s]))`,
+						cfg.Namespace, matcher, w),
+				})
+			}
+			alerting.Rules = append(alerting.Rules, burnRateAlerts(
+				cfg.Namespace+"_slo_latency_budget_burn", sloSlowRecord, matcher, 1-slo.LatencyObjective, slo.window(),
+				fmt.Sprintf("SLO %s is burning its latency error budget (%g%% of requests faster than %s)", slo.Name, slo.LatencyObjective*100, slo.LatencyThreshold),
+			)...)
+		}
+	}
+
+	return yaml.Marshal(ruleGroups{Groups: []ruleGroup{recording, alerting}})
+}
+
+func burnRateAlerts(alert string, record string, matcher string, budget float64, window time.Duration, summary string) []rule {
+	var rules []rule
+	for _, w := range burnRateWindows {
+		factor := w.budget * float64(window) / float64(w.span)
+		threshold := strconv.FormatFloat(factor*budget, 'g', 6, 64)
+		rules = append(rules, rule{
+			Alert: alert,
+			Expr: fmt.Sprintf(`%[1]s%[2]s{%[4]s} > %[5]s and %[1]s%[3]s{%[4]s} > %[5]s`,
+				record, w.long, w.short, matcher, threshold),
+			Labels: map[string]string{
+				"severity": w.severity,
+				"window":   w.long,
+			},
+			Annotations: map[string]string{
+				"summary": summary,
+			},
+		})
+	}
+	return rules
+}
+
+func containsFloat(fs []float64, f float64) bool {
+	for _, v := range fs {
+		if v == f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package muxprom
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	SchemaV1 = 1
+	SchemaV2 = 2
+)
+
+func validSchemaVersion(v int) error {
+	if v != SchemaV1 && v != SchemaV2 {
+		return fmt.Errorf("muxprom: unknown schema version %d", v)
+	}
+	return nil
+}
+
+func sizeMetricName(schema int, name string) string {
+	if schema >= SchemaV2 {
+		return name + "_bytes"
+	}
+	return name
+}
+
+func MuxRouteTemplateLabeler(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return r.RequestURI
+	}
+	if tpl, err := route.GetPathTemplate(); err == nil && tpl != "" {
+		return tpl
+	}
+	return route.GetName()
+}
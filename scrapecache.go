@@ -0,0 +1,30 @@
+package muxprom
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type cachingGatherer struct {
+	gatherer prometheus.Gatherer
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	gathered time.Time
+	mfs      []*dto.MetricFamily
+	err      error
+}
+
+func (g *cachingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Since(g.gathered) < g.ttl {
+		return g.mfs, g.err
+	}
+	g.mfs, g.err = g.gatherer.Gather()
+	g.gathered = time.Now()
+	return g.mfs, g.err
+}
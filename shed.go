@@ -0,0 +1,60 @@
+package muxprom
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type loadShedder struct {
+	limit    int64
+	inflight atomic.Int64
+	routes   map[string]*routeInflight
+	shed     *prometheus.CounterVec
+}
+
+type routeInflight struct {
+	limit    int64
+	inflight atomic.Int64
+}
+
+func newLoadShedder(prom *MuxProm) *loadShedder {
+	s := &loadShedder{
+		limit:  int64(prom.ShedInflight),
+		routes: make(map[string]*routeInflight),
+		shed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_requests_shed_total",
+				Help:      "HTTP requests rejected with 503 because of too many requests in flight",
+			},
+			[]string{"route"},
+		),
+	}
+	for route, limit := range prom.ShedRouteInflight {
+		s.routes[route] = &routeInflight{limit: int64(limit)}
+	}
+	return s
+}
+
+func (s *loadShedder) acquire(route string) (func(), bool) {
+	if s.inflight.Add(1) > s.limit && s.limit > 0 {
+		s.inflight.Add(-1)
+		s.shed.WithLabelValues(route).Inc()
+		return noRelease, false
+	}
+	ri, ok := s.routes[route]
+	if !ok {
+		return func() { s.inflight.Add(-1) }, true
+	}
+	if ri.inflight.Add(1) > ri.limit {
+		ri.inflight.Add(-1)
+		s.inflight.Add(-1)
+		s.shed.WithLabelValues(route).Inc()
+		return noRelease, false
+	}
+	return func() {
+		ri.inflight.Add(-1)
+		s.inflight.Add(-1)
+	}, true
+}
@@ -0,0 +1,56 @@
+package muxprom
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLoadShedding(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*MuxProm)
+		block   bool
+		want    int
+	}{
+		{name: "below global limit", options: []func(*MuxProm){ShedInflight(2)}, block: true, want: http.StatusOK},
+		{name: "global limit", options: []func(*MuxProm){ShedInflight(1)}, block: true, want: http.StatusServiceUnavailable},
+		{name: "route limit", options: []func(*MuxProm){ShedRouteInflight("slow", 1)}, block: true, want: http.StatusServiceUnavailable},
+		{name: "route limit zero", options: []func(*MuxProm){ShedRouteInflight("slow", 0)}, want: http.StatusServiceUnavailable},
+		{name: "other route limit", options: []func(*MuxProm){ShedRouteInflight("fast", 0)}, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, prom, entered, unblock := newBlockingRouter(t, tt.options...)
+			var first <-chan int
+			if tt.block {
+				first = serveAsync(router, "/slow")
+				<-entered
+			}
+			second := serveAsync(router, "/slow")
+			if tt.want == http.StatusOK {
+				<-entered
+			} else if code := <-second; code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+			close(unblock)
+			if first != nil {
+				if code := <-first; code != http.StatusOK {
+					t.Errorf("first request: status %d, want %d", code, http.StatusOK)
+				}
+			}
+			if tt.want == http.StatusOK {
+				if code := <-second; code != tt.want {
+					t.Errorf("status %d, want %d", code, tt.want)
+				}
+			}
+
+			shed := 0.0
+			if tt.want == http.StatusServiceUnavailable {
+				shed = 1
+			}
+			if got := metricValue(t, prom, "http_requests_shed_total", "route", "slow"); got != shed {
+				t.Errorf("shed %v, want %v", got, shed)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package muxprom
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultSLOWindow = 30 * 24 * time.Hour
+
+type SLO struct {
+	Name             string
+	Routes           []string
+	Networks         []string
+	Availability     float64
+	LatencyThreshold time.Duration
+	LatencyObjective float64
+	Window           time.Duration
+}
+
+func (s SLO) window() time.Duration {
+	if s.Window <= 0 {
+		return defaultSLOWindow
+	}
+	return s.Window
+}
+
+func (s SLO) AvailabilityBurnRate(errorRatio float64) float64 {
+	return burnRate(errorRatio, s.Availability)
+}
+
+func (s SLO) LatencyBurnRate(slowRatio float64) float64 {
+	return burnRate(slowRatio, s.LatencyObjective)
+}
+
+func burnRate(ratio float64, objective float64) float64 {
+	if objective <= 0 || objective >= 1 {
+		return 0
+	}
+	return ratio / (1 - objective)
+}
+
+func validSLOs(slos []SLO) error {
+	names := make(map[string]bool, len(slos))
+	for _, s := range slos {
+		if s.Name == "" {
+			return fmt.Errorf("muxprom: SLO without a name")
+		}
+		if names[s.Name] {
+			return fmt.Errorf("muxprom: duplicate SLO %q", s.Name)
+		}
+		names[s.Name] = true
+		if s.Availability < 0 || s.Availability >= 1 {
+			return fmt.Errorf("muxprom: availability objective %g of SLO %q is not in [0, 1)", s.Availability, s.Name)
+		}
+		if s.LatencyObjective < 0 || s.LatencyObjective >= 1 {
+			return fmt.Errorf("muxprom: latency objective %g of SLO %q is not in [0, 1)", s.LatencyObjective, s.Name)
+		}
+		if (s.LatencyThreshold > 0) != (s.LatencyObjective > 0) {
+			return fmt.Errorf("muxprom: SLO %q needs both a latency threshold and a latency objective", s.Name)
+		}
+		if s.Availability == 0 && s.LatencyObjective == 0 {
+			return fmt.Errorf("muxprom: SLO %q has no objective", s.Name)
+		}
+	}
+	return nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type sloTracker struct {
+	all       []*SLO
+	routes    map[string][]*SLO
+	requests  *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	slow      *prometheus.CounterVec
+	objective *prometheus.GaugeVec
+}
+
+func newSLOTracker(prom *MuxProm) *sloTracker {
+	t := &sloTracker{
+		routes: make(map[string][]*SLO),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "slo_requests_total",
+				Help:      "HTTP requests counted towards an SLO",
+			},
+			[]string{"slo"},
+		),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "slo_errors_total",
+				Help:      "HTTP requests failing the availability objective of an SLO",
+			},
+			[]string{"slo"},
+		),
+		slow: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "slo_slow_requests_total",
+				Help:      "HTTP requests slower than the latency threshold of an SLO",
+			},
+			[]string{"slo"},
+		),
+		objective: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: prom.Namespace,
+				Name:      "slo_objective",
+				Help:      "Objective of an SLO by indicator",
+			},
+			[]string{"slo", "sli"},
+		),
+	}
+	for i := range prom.SLOs {
+		s := &prom.SLOs[i]
+		if len(s.Routes) == 0 {
+			t.all = append(t.all, s)
+		}
+		for _, route := range s.Routes {
+			t.routes[route] = append(t.routes[route], s)
+		}
+		t.requests.WithLabelValues(s.Name)
+		if s.Availability > 0 {
+			t.errors.WithLabelValues(s.Name)
+			t.objective.WithLabelValues(s.Name, "availability").Set(s.Availability)
+		}
+		if s.LatencyObjective > 0 {
+			t.slow.WithLabelValues(s.Name)
+			t.objective.WithLabelValues(s.Name, "latency").Set(s.LatencyObjective)
+		}
+	}
+	return t
+}
+
+func (t *sloTracker) observe(route string, network string, status int, d time.Duration) {
+	for _, slos := range [][]*SLO{t.all, t.routes[route]} {
+		for _, s := range slos {
+			if len(s.Networks) > 0 && !containsString(s.Networks, network) {
+				continue
+			}
+			t.requests.WithLabelValues(s.Name).Inc()
+			if s.Availability > 0 && status >= 500 {
+				t.errors.WithLabelValues(s.Name).Inc()
+			}
+			if s.LatencyObjective > 0 && d > s.LatencyThreshold {
+				t.slow.WithLabelValues(s.Name).Inc()
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package muxprom
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var defaultStatsRouteName = "muxprom-stats"
+
+// statsMaxRoutes bounds the routes with a window; each takes about 10KB.
+var statsMaxRoutes = 500
+
+const (
+	statsSlot  = 10 * time.Second
+	statsSlots = 30
+
+	// Latency buckets grow by statsGrowth from statsMinLatency, so quantiles
+	// are accurate to about 10%.
+	statsMinLatency = 50 * time.Microsecond
+	statsGrowth     = 1.2
+	statsBuckets    = 80
+)
+
+type WindowStats struct {
+	Requests    int64   `json:"requests"`
+	Errors      int64   `json:"errors"`
+	RequestRate float64 `json:"request_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+	P50         float64 `json:"p50_seconds"`
+	P95         float64 `json:"p95_seconds"`
+	P99         float64 `json:"p99_seconds"`
+}
+
+type RouteStats struct {
+	Route     string      `json:"route"`
+	LastMin   WindowStats `json:"1m"`
+	Last5Mins WindowStats `json:"5m"`
+}
+
+type statsSlotData struct {
+	epoch    int64
+	requests int64
+	errors   int64
+	buckets  [statsBuckets]uint32
+}
+
+type routeWindow struct {
+	last  int64
+	slots [statsSlots]statsSlotData
+}
+
+// stale reports whether none of the window's slots is recent enough to count.
+func (w *routeWindow) stale(epoch int64) bool {
+	return w.last <= epoch-statsSlots
+}
+
+type slidingStats struct {
+	clock Clock
+
+	mu     sync.Mutex
+	routes map[string]*routeWindow
+}
+
+func StatsPath(path string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.StatsPath = path
+	}
+}
+
+func StatsRouteName(rn string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.StatsRouteName = rn
+	}
+}
+
+func newSlidingStats(prom *MuxProm) *slidingStats {
+	return &slidingStats{clock: prom.Clock, routes: make(map[string]*routeWindow)}
+}
+
+func statsBucket(d time.Duration) int {
+	if d <= statsMinLatency {
+		return 0
+	}
+	i := int(math.Log(float64(d)/float64(statsMinLatency))/math.Log(statsGrowth)) + 1
+	if i >= statsBuckets {
+		return statsBuckets - 1
+	}
+	return i
+}
+
+func statsBucketUpperBound(i int) float64 {
+	return statsMinLatency.Seconds() * math.Pow(statsGrowth, float64(i))
+}
+
+func (s *slidingStats) observe(route string, status int, d time.Duration) {
+	epoch := s.clock.Now().UnixNano() / int64(statsSlot)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.routes[route]
+	if !ok {
+		if len(s.routes) >= statsMaxRoutes {
+			s.evict(epoch)
+		}
+		if len(s.routes) >= statsMaxRoutes {
+			return
+		}
+		w = &routeWindow{}
+		s.routes[route] = w
+	}
+	w.last = epoch
+	slot := &w.slots[epoch%statsSlots]
+	if slot.epoch != epoch {
+		*slot = statsSlotData{epoch: epoch}
+	}
+	slot.requests++
+	if status >= 500 {
+		slot.errors++
+	}
+	slot.buckets[statsBucket(d)]++
+}
+
+func (s *slidingStats) snapshot() []RouteStats {
+	epoch := s.clock.Now().UnixNano() / int64(statsSlot)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict(epoch)
+	stats := make([]RouteStats, 0, len(s.routes))
+	for route, w := range s.routes {
+		stats = append(stats, RouteStats{
+			Route:     route,
+			LastMin:   w.window(epoch, 6),
+			Last5Mins: w.window(epoch, statsSlots),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Route < stats[j].Route })
+	return stats
+}
+
+// evict drops the windows of routes without requests in the last 5 minutes.
+func (s *slidingStats) evict(epoch int64) {
+	for route, w := range s.routes {
+		if w.stale(epoch) {
+			delete(s.routes, route)
+		}
+	}
+}
+
+// window aggregates the n most recent slots, including the current one.
+func (w *routeWindow) window(epoch int64, n int64) WindowStats {
+	var ws WindowStats
+	var buckets [statsBuckets]uint64
+	for e := epoch - n + 1; e <= epoch; e++ {
+		slot := &w.slots[e%statsSlots]
+		if slot.epoch != e {
+			continue
+		}
+		ws.Requests += slot.requests
+		ws.Errors += slot.errors
+		for i, c := range slot.buckets {
+			buckets[i] += uint64(c)
+		}
+	}
+	if ws.Requests == 0 {
+		return ws
+	}
+	seconds := (time.Duration(n) * statsSlot).Seconds()
+	ws.RequestRate = float64(ws.Requests) / seconds
+	ws.ErrorRate = float64(ws.Errors) / float64(ws.Requests)
+	ws.P50 = statsQuantile(buckets[:], ws.Requests, 0.5)
+	ws.P95 = statsQuantile(buckets[:], ws.Requests, 0.95)
+	ws.P99 = statsQuantile(buckets[:], ws.Requests, 0.99)
+	return ws
+}
+
+func statsQuantile(buckets []uint64, total int64, q float64) float64 {
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range buckets {
+		seen += c
+		if seen >= rank {
+			return statsBucketUpperBound(i)
+		}
+	}
+	return statsBucketUpperBound(len(buckets) - 1)
+}
+
+func (prom *MuxProm) Stats() []RouteStats {
+	if prom.stats == nil {
+		return nil
+	}
+	return prom.stats.snapshot()
+}
+
+func (prom *MuxProm) statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(prom.Stats()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
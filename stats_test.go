@@ -0,0 +1,114 @@
+package muxprom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	router := mux.NewRouter()
+	router.Name("ok").Path("/ok").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Name("fail").Path("/fail").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	prom, err := New(Router(router), Registry(prometheus.NewRegistry()), StatsPath("/stats"), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prom.Instrument(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/ok", "/ok", "/ok", "/fail"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	tests := []struct {
+		advance  time.Duration
+		route    string
+		requests int64
+		errors   int64
+		total5m  int64
+	}{
+		{advance: 0, route: "fail", requests: 1, errors: 1, total5m: 1},
+		{advance: 0, route: "ok", requests: 3, errors: 0, total5m: 3},
+		{advance: 2 * time.Minute, route: "ok", requests: 0, errors: 0, total5m: 3},
+		{advance: 4 * time.Minute, route: "ok", requests: -1},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("stats returned %d", rec.Code)
+		}
+		var stats []RouteStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+			t.Fatal(err)
+		}
+		var found *RouteStats
+		for i := range stats {
+			if stats[i].Route == tt.route {
+				found = &stats[i]
+			}
+		}
+		if tt.requests < 0 {
+			if found != nil {
+				t.Errorf("route %q still reported after 6 minutes: %+v", tt.route, *found)
+			}
+			continue
+		}
+		if found == nil {
+			t.Fatalf("route %q missing from %s", tt.route, rec.Body.String())
+		}
+		if found.LastMin.Requests != tt.requests || found.LastMin.Errors != tt.errors || found.Last5Mins.Requests != tt.total5m {
+			t.Errorf("route %q: %+v", tt.route, *found)
+		}
+	}
+}
+
+func TestStatsRouteLimit(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	s := &slidingStats{clock: clock, routes: make(map[string]*routeWindow)}
+	for i := 0; i < statsMaxRoutes+100; i++ {
+		s.observe(fmt.Sprintf("/items/%d", i), http.StatusOK, time.Millisecond)
+	}
+	if len(s.routes) != statsMaxRoutes {
+		t.Fatalf("%d routes tracked, want %d", len(s.routes), statsMaxRoutes)
+	}
+
+	clock.Advance(5 * time.Minute)
+	s.observe("/new", http.StatusOK, time.Millisecond)
+	if len(s.routes) != 1 {
+		t.Fatalf("%d routes tracked after the others went idle, want 1", len(s.routes))
+	}
+}
@@ -0,0 +1,136 @@
+package muxprom
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var defaultTenantLimit = 100
+
+type tenantMetrics struct {
+	registry *prometheus.Registry
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	legacy   *prometheus.HistogramVec
+}
+
+type tenantRegistries struct {
+	prom     *MuxProm
+	rejected prometheus.Counter
+
+	mu      sync.RWMutex
+	tenants map[string]*tenantMetrics
+}
+
+func newTenantRegistries(prom *MuxProm) *tenantRegistries {
+	return &tenantRegistries{
+		prom:    prom,
+		tenants: make(map[string]*tenantMetrics),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: prom.Namespace,
+			Name:      "tenant_limit_exceeded_total",
+			Help:      "HTTP requests not recorded per tenant because the tenant limit was reached",
+		}),
+	}
+}
+
+func (t *tenantRegistries) get(tenant string) *tenantMetrics {
+	t.mu.RLock()
+	m, ok := t.tenants[tenant]
+	t.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if m, ok := t.tenants[tenant]; ok {
+		return m
+	}
+	if len(t.tenants) >= t.prom.TenantLimit {
+		return nil
+	}
+	m = &tenantMetrics{
+		registry: prometheus.NewRegistry(),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: t.prom.Namespace,
+				Name:      "http_request_duration_seconds",
+				Help:      "HTTP request duration seconds",
+				Buckets:   t.prom.DurationBucket,
+			},
+			[]string{"route", "method", "http_status"},
+		),
+		size: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: t.prom.Namespace,
+				Name:      sizeMetricName(t.prom.SchemaVersion, "http_response_size"),
+				Help:      "HTTP response size in bytes",
+				Buckets:   t.prom.RespSizeBucket,
+			},
+			[]string{"route", "method", "http_status"},
+		),
+	}
+	m.registry.MustRegister(m.duration, m.size)
+	if t.prom.LegacyMetricNames && t.prom.SchemaVersion >= SchemaV2 {
+		m.legacy = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: t.prom.Namespace,
+				Name:      "http_response_size",
+				Help:      "HTTP response size in bytes (deprecated, use http_response_size_bytes)",
+				Buckets:   t.prom.RespSizeBucket,
+			},
+			[]string{"route", "method", "http_status"},
+		)
+		m.registry.MustRegister(m.legacy)
+	}
+	t.tenants[tenant] = m
+	return m
+}
+
+func (t *tenantRegistries) observe(tenant string, route string, method string, status int, d time.Duration, bytes int, observeSize bool) {
+	if tenant == "" {
+		return
+	}
+	m := t.get(tenant)
+	if m == nil {
+		t.rejected.Inc()
+		return
+	}
+	m.duration.WithLabelValues(route, method, strconv.Itoa(status)).Observe(d.Seconds())
+	if observeSize {
+		m.size.WithLabelValues(route, method, strconv.Itoa(status)).Observe(float64(bytes))
+		if m.legacy != nil {
+			m.legacy.WithLabelValues(route, method, strconv.Itoa(status)).Observe(float64(bytes))
+		}
+	}
+}
+
+func (t *tenantRegistries) count() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.tenants)
+}
+
+func (t *tenantRegistries) handler(next http.Handler, opts promhttp.HandlerOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.URL.Query().Get("tenant")
+		if tenant == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t.mu.RLock()
+		m, ok := t.tenants[tenant]
+		t.mu.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		promhttp.HandlerFor(m.registry, opts).ServeHTTP(w, r)
+	})
+}
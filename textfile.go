@@ -0,0 +1,23 @@
+package muxprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (prom *MuxProm) WriteTextfile(filename string) error {
+	return prometheus.WriteToTextfile(filename, prom.gatherer())
+}
+
+func (prom *MuxProm) StartTextfile(filename string, interval time.Duration) (stop func()) {
+	write := func() error {
+		return prom.WriteTextfile(filename)
+	}
+	stopPeriodic := startPeriodic(prom.Logger, interval, "writing textfile "+filename, write, write)
+	return func() {
+		if err := stopPeriodic(); err != nil {
+			prom.Logger.Error("muxprom: writing textfile "+filename+" failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package muxprom
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type checkpoint struct {
+	stage string
+	at    time.Time
+}
+
+func (prom *MuxProm) Checkpoint(ctx context.Context, stage string) {
+	state, ok := ctx.Value(requestStateKey{}).(*requestState)
+	if !ok {
+		return
+	}
+	now := prom.Clock.Now()
+	state.mu.Lock()
+	state.checkpoints = append(state.checkpoints, checkpoint{stage: stage, at: now})
+	state.mu.Unlock()
+}
+
+func (prom *MuxProm) observeCheckpoints(state *requestState, method string) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	prev := state.start
+	for _, cp := range state.checkpoints {
+		prom.reqStageHistogram.WithLabelValues(state.route, method, cp.stage).Observe(cp.at.Sub(prev).Seconds())
+		prev = cp.at
+	}
+}
+
+func (prom *MuxProm) MarkHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, ok := r.Context().Value(requestStateKey{}).(*requestState)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		state.handlerStart = prom.Clock.Now()
+		next.ServeHTTP(w, r)
+		state.handlerEnd = prom.Clock.Now()
+	})
+}
+
+func (prom *MuxProm) observePhases(state *requestState, method string, start time.Time, total time.Duration, writeTime time.Duration) {
+	handlerTime := total
+	if !state.handlerStart.IsZero() && !state.handlerEnd.IsZero() {
+		prom.reqPhaseHistogram.WithLabelValues(state.route, method, "before_handler").Observe(state.handlerStart.Sub(start).Seconds())
+		handlerTime = state.handlerEnd.Sub(state.handlerStart)
+	}
+	prom.reqPhaseHistogram.WithLabelValues(state.route, method, "handler").Observe((handlerTime - writeTime).Seconds())
+	prom.reqPhaseHistogram.WithLabelValues(state.route, method, "write").Observe(writeTime.Seconds())
+}
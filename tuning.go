@@ -0,0 +1,145 @@
+package muxprom
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const tuningReservoirSize = 1000
+
+// tuningMaxRoutes bounds the routes sampled during the tuning window; each
+// reservoir takes up to 8KB.
+var tuningMaxRoutes = 100
+
+var tuningQuantiles = []float64{.05, .25, .5, .75, .9, .95, .99, .999}
+
+type tuningReservoir struct {
+	samples []float64
+	count   int
+}
+
+type bucketTuner struct {
+	mu        sync.Mutex
+	logger    Logger
+	deadline  time.Time
+	routes    map[string]*tuningReservoir
+	suggested map[string][]float64
+	timer     *time.Timer
+}
+
+func newBucketTuner(prom *MuxProm) *bucketTuner {
+	t := &bucketTuner{
+		logger:   prom.Logger,
+		deadline: prom.Clock.Now().Add(prom.BucketTuningWindow),
+		routes:   make(map[string]*tuningReservoir),
+	}
+	// The suggestions are logged when the window ends, even without traffic
+	// or a SuggestedBuckets call at that time.
+	t.timer = time.AfterFunc(prom.BucketTuningWindow, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.finishLocked()
+	})
+	return t
+}
+
+func (t *bucketTuner) stop() {
+	t.timer.Stop()
+}
+
+func (t *bucketTuner) observe(route string, d time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.doneLocked(now) {
+		return
+	}
+	res, ok := t.routes[route]
+	if !ok {
+		if len(t.routes) >= tuningMaxRoutes {
+			return
+		}
+		res = &tuningReservoir{}
+		t.routes[route] = res
+	}
+	res.count++
+	if len(res.samples) < tuningReservoirSize {
+		res.samples = append(res.samples, d.Seconds())
+	} else if i := rand.Intn(res.count); i < tuningReservoirSize {
+		res.samples[i] = d.Seconds()
+	}
+}
+
+func (t *bucketTuner) result(now time.Time) (map[string][]float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.doneLocked(now) {
+		return nil, false
+	}
+	suggested := make(map[string][]float64, len(t.suggested))
+	for route, buckets := range t.suggested {
+		suggested[route] = append([]float64(nil), buckets...)
+	}
+	return suggested, true
+}
+
+// doneLocked computes the suggestions once the warm-up window is over and
+// reports whether they are available.
+func (t *bucketTuner) doneLocked(now time.Time) bool {
+	if t.suggested == nil && now.Before(t.deadline) {
+		return false
+	}
+	t.finishLocked()
+	return true
+}
+
+func (t *bucketTuner) finishLocked() {
+	if t.suggested != nil {
+		return
+	}
+	t.suggested = make(map[string][]float64, len(t.routes))
+	for route, res := range t.routes {
+		buckets := suggestBuckets(res.samples)
+		t.suggested[route] = buckets
+		t.logger.Info("muxprom: suggested duration buckets",
+			"route", route, "buckets", buckets, "samples", res.count)
+	}
+	t.routes = nil
+}
+
+func suggestBuckets(samples []float64) []float64 {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	var buckets []float64
+	for _, q := range tuningQuantiles {
+		if len(sorted) == 0 {
+			break
+		}
+		v := roundSignificant(sorted[int(q*float64(len(sorted)-1))], 2)
+		if v > 0 && (len(buckets) == 0 || v > buckets[len(buckets)-1]) {
+			buckets = append(buckets, v)
+		}
+	}
+	return buckets
+}
+
+func roundSignificant(v float64, digits int) float64 {
+	if v <= 0 {
+		return 0
+	}
+	scale := math.Pow(10, float64(digits)-math.Ceil(math.Log10(v)))
+	rounded := math.Ceil(v*scale) / scale
+	// Trim the float noise left by the scaling.
+	rounded, _ = strconv.ParseFloat(strconv.FormatFloat(rounded, 'g', digits, 64), 64)
+	return rounded
+}
+
+func (prom *MuxProm) SuggestedBuckets() (map[string][]float64, bool) {
+	if prom.tuner == nil {
+		return nil, false
+	}
+	return prom.tuner.result(prom.Clock.Now())
+}
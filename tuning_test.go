@@ -0,0 +1,61 @@
+package muxprom
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type infoRecorder struct {
+	Logger
+	infos chan string
+}
+
+func (l infoRecorder) Info(msg string, args ...any) {
+	l.infos <- fmt.Sprint(append([]any{msg}, args...)...)
+}
+
+func TestBucketTuningLogsWhenWindowEnds(t *testing.T) {
+	logger := infoRecorder{Logger: defaultLogger(), infos: make(chan string, 10)}
+	prom, err := New(Registry(prometheus.NewRegistry()), BucketTuning(20*time.Millisecond), WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prom.Close()
+	prom.tuner.observe("api", 3*time.Millisecond, prom.Clock.Now())
+
+	select {
+	case msg := <-logger.infos:
+		t.Log(msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no suggestion logged after the tuning window")
+	}
+	buckets, ok := prom.SuggestedBuckets()
+	if !ok || len(buckets["api"]) == 0 {
+		t.Errorf("SuggestedBuckets() = %v, %v", buckets, ok)
+	}
+}
+
+func TestBucketTuningRouteLimit(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	prom, err := New(Registry(prometheus.NewRegistry()), BucketTuning(time.Hour), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prom.Close()
+	for i := 0; i < tuningMaxRoutes+50; i++ {
+		prom.tuner.observe(fmt.Sprintf("/items/%d", i), time.Millisecond, clock.Now())
+	}
+	if n := len(prom.tuner.routes); n != tuningMaxRoutes {
+		t.Errorf("%d routes sampled, want %d", n, tuningMaxRoutes)
+	}
+	if _, ok := prom.SuggestedBuckets(); ok {
+		t.Error("suggestions available before the window ended")
+	}
+	clock.Advance(time.Hour)
+	if buckets, ok := prom.SuggestedBuckets(); !ok || len(buckets) != tuningMaxRoutes {
+		t.Errorf("%d suggestions, want %d", len(buckets), tuningMaxRoutes)
+	}
+}
@@ -0,0 +1,63 @@
+package muxprom
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var uploadThroughputBucket = []float64{
+	16 * bytefmt.KILOBYTE, 64 * bytefmt.KILOBYTE, 256 * bytefmt.KILOBYTE,
+	bytefmt.MEGABYTE, 4 * bytefmt.MEGABYTE, 16 * bytefmt.MEGABYTE,
+	64 * bytefmt.MEGABYTE, 256 * bytefmt.MEGABYTE, bytefmt.GIGABYTE,
+}
+
+type uploads struct {
+	routes     map[string]bool
+	duration   *prometheus.HistogramVec
+	throughput *prometheus.HistogramVec
+}
+
+func UploadRoutes(routes ...string) func(*MuxProm) {
+	return func(prom *MuxProm) {
+		prom.UploadRoutes = append(prom.UploadRoutes, routes...)
+	}
+}
+
+func newUploads(prom *MuxProm) *uploads {
+	u := &uploads{
+		routes: make(map[string]bool, len(prom.UploadRoutes)),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_body_read_duration_seconds",
+				Help:      "Time from the first to the last read of the HTTP request body by route",
+				Buckets:   prom.DurationBucket,
+			},
+			[]string{"route", "method"},
+		),
+		throughput: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_request_upload_throughput_bytes_per_second",
+				Help:      "Effective HTTP request body upload throughput by route",
+				Buckets:   uploadThroughputBucket,
+			},
+			[]string{"route", "method"},
+		),
+	}
+	for _, route := range prom.UploadRoutes {
+		u.routes[route] = true
+	}
+	return u
+}
+
+func (u *uploads) observe(body *countingReadCloser, route string, method string) {
+	if body.firstRead.IsZero() {
+		return
+	}
+	d := body.lastRead.Sub(body.firstRead)
+	u.duration.WithLabelValues(route, method).Observe(d.Seconds())
+	if d > 0 && body.length > 0 {
+		u.throughput.WithLabelValues(route, method).Observe(float64(body.length) / d.Seconds())
+	}
+}
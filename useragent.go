@@ -0,0 +1,83 @@
+package muxprom
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var maxAgentClasses = 10
+
+var (
+	botAgentMarkers       = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "headless", "preview"}
+	apiClientAgentMarkers = []string{"curl/", "wget/", "go-http-client/", "python-requests/", "python-urllib/", "aiohttp/", "okhttp/", "axios/", "node-fetch/", "postmanruntime/", "java/", "apache-httpclient/", "libwww-perl/", "insomnia/"}
+	mobileAgentMarkers    = []string{"mobile", "android", "iphone", "ipad"}
+)
+
+type agentClasses struct {
+	classify func(*http.Request) string
+	requests *prometheus.CounterVec
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func ClassifyUserAgentHeader(r *http.Request) string {
+	ua := strings.ToLower(r.UserAgent())
+	switch {
+	case ua == "":
+		return "other"
+	case containsAny(ua, botAgentMarkers):
+		return "bot"
+	case containsAny(ua, apiClientAgentMarkers):
+		return "api-client"
+	case containsAny(ua, mobileAgentMarkers):
+		return "mobile"
+	case strings.HasPrefix(ua, "mozilla/"):
+		return "browser"
+	}
+	return "other"
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func newAgentClasses(prom *MuxProm) *agentClasses {
+	return &agentClasses{
+		classify: prom.ClassifyUserAgent,
+		seen:     make(map[string]struct{}),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: prom.Namespace,
+				Name:      "http_requests_by_agent_total",
+				Help:      "HTTP requests by user agent class",
+			},
+			[]string{"route", "method", "agent_class"},
+		),
+	}
+}
+
+func (a *agentClasses) observe(r *http.Request, route string) {
+	class := a.classify(r)
+	if class == "" {
+		class = "other"
+	}
+	a.mu.Lock()
+	if _, ok := a.seen[class]; !ok {
+		if len(a.seen) >= maxAgentClasses {
+			class = "other"
+		} else {
+			a.seen[class] = struct{}{}
+		}
+	}
+	a.mu.Unlock()
+	a.requests.WithLabelValues(route, r.Method, class).Inc()
+}
@@ -0,0 +1,153 @@
+package muxprom
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	FindingRawURI         = "raw_uri"
+	FindingCatchAllPrefix = "catch_all_prefix"
+	FindingRegexVar       = "regex_var"
+)
+
+type Finding struct {
+	Kind    string
+	Route   string
+	Path    string
+	Message string
+}
+
+func (f Finding) String() string {
+	switch {
+	case f.Route != "":
+		return fmt.Sprintf("%s: route %q (%s): %s", f.Kind, f.Route, f.Path, f.Message)
+	case f.Path != "":
+		return fmt.Sprintf("%s: %s: %s", f.Kind, f.Path, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", f.Kind, f.Message)
+}
+
+func (prom *MuxProm) Validate() []Finding {
+	prom.mu.RLock()
+	labeler := prom.RouteLabeler
+	prom.mu.RUnlock()
+
+	var findings []Finding
+	if prom.labelsRawURIs(labeler) {
+		findings = append(findings, Finding{
+			Kind:    FindingRawURI,
+			Message: "requests matching no route are labeled with their raw request URI; use a RouteLabeler that maps them to a fixed label",
+		})
+	}
+
+	routers.Lock()
+	defer routers.Unlock()
+	roots := prom.subrouters
+	if prom.Router != nil {
+		roots = []*mux.Router{prom.Router}
+	}
+	for _, root := range roots {
+		root.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			if route.GetHandler() == nil || prom.isOwnRouteName(route.GetName()) {
+				return nil
+			}
+			findings = append(findings, routeFindings(route)...)
+			return nil
+		})
+	}
+	return findings
+}
+
+// labelsRawURIs reports whether a built-in labeler sees requests that match
+// no route, which it labels with the request URI.
+func (prom *MuxProm) labelsRawURIs(labeler func(*http.Request) string) bool {
+	if !sameFunc(labeler, MuxRouteLabeler) && !sameFunc(labeler, MuxRouteTemplateLabeler) &&
+		!sameFunc(labeler, ServeMuxRouteLabeler(nil)) {
+		return false
+	}
+	if prom.Router != nil {
+		routers.Lock()
+		defer routers.Unlock()
+		return routers.owners[prom.Router] == prom
+	}
+	if prom.ServeMux != nil {
+		probe := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/muxprom-validate-unmatched"}}
+		_, pattern := prom.ServeMux.Handler(probe)
+		return pattern == ""
+	}
+	return false
+}
+
+// sameFunc compares code pointers, so closures created by the same function
+// literal are equal.
+func sameFunc(a, b func(*http.Request) string) bool {
+	return a != nil && reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func routeFindings(route *mux.Route) []Finding {
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	var findings []Finding
+	name := route.GetName()
+	if re, err := route.GetPathRegexp(); err == nil && !strings.HasSuffix(re, "$") {
+		findings = append(findings, Finding{
+			Kind:    FindingCatchAllPrefix,
+			Route:   name,
+			Path:    tpl,
+			Message: "PathPrefix route matches every path below it; all of them share one label and a RouteLabeler deriving labels from the URL path would be unbounded",
+		})
+	}
+	for _, v := range pathVars(tpl) {
+		pattern := v[1]
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil || !re.MatchString("a/b") {
+			continue
+		}
+		findings = append(findings, Finding{
+			Kind:    FindingRegexVar,
+			Route:   name,
+			Path:    tpl,
+			Message: fmt.Sprintf("variable %q matches across path segments (%s), so the route accepts arbitrarily deep paths", v[0], pattern),
+		})
+	}
+	return findings
+}
+
+// pathVars returns the name and pattern of each {name:pattern} variable of a
+// path template, with an empty pattern for plain {name} variables.
+func pathVars(tpl string) [][2]string {
+	var vars [][2]string
+	level, start := 0, 0
+	for i := 0; i < len(tpl); i++ {
+		switch tpl[i] {
+		case '{':
+			if level == 0 {
+				start = i + 1
+			}
+			level++
+		case '}':
+			level--
+			if level == 0 {
+				parts := strings.SplitN(tpl[start:i], ":", 2)
+				v := [2]string{parts[0]}
+				if len(parts) == 2 {
+					v[1] = parts[1]
+				}
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars
+}